@@ -19,5 +19,6 @@ func main() {
 	err = btree.Push(13, 14)
 	res, err = btree.Get(13)
 	fmt.Printf("result is: %v\n", res)
-	_ = btree.Manager.Flush()
+	// Close writes the dirty pages back and releases the lock of the tree file
+	_ = myBuffer.Close()
 }
@@ -0,0 +1,157 @@
+package src
+
+import "container/list"
+
+/*
+arcReplacer implements the adaptive replacement cache. Resident pages seen once are kept in t1, pages seen
+at least twice in t2. The ghost lists b1 and b2 remember pages recently evicted from t1 and t2, a miss on a
+ghost page shifts the target size p of t1 towards the list that would have kept it.
+*/
+type arcReplacer struct {
+	capacity  int
+	p         int // target size of t1
+	t1        *list.List
+	t2        *list.List
+	b1        *list.List // page keys, the most recently evicted one at the front
+	b2        *list.List
+	elements  map[uint64]*list.Element
+	lists     map[uint64]*list.List // the list each tracked frame is in
+	pages     map[uint64]PageKey    // the page each tracked frame holds
+	ghosts    map[PageKey]*list.Element
+	ghostIn   map[PageKey]*list.List // the ghost list each remembered page is in
+	evictable map[uint64]bool
+}
+
+func newARCReplacer(frames int) *arcReplacer {
+	return &arcReplacer{
+		capacity:  frames,
+		t1:        list.New(),
+		t2:        list.New(),
+		b1:        list.New(),
+		b2:        list.New(),
+		elements:  make(map[uint64]*list.Element),
+		lists:     make(map[uint64]*list.List),
+		pages:     make(map[uint64]PageKey),
+		ghosts:    make(map[PageKey]*list.Element),
+		ghostIn:   make(map[PageKey]*list.List),
+		evictable: make(map[uint64]bool),
+	}
+}
+
+func (r *arcReplacer) RecordAccess(frameID uint64, page PageKey) {
+	if l, ok := r.lists[frameID]; ok {
+		// a hit in t1 or t2 makes the page frequent
+		l.Remove(r.elements[frameID])
+		r.insert(r.t2, frameID, page)
+		return
+	}
+
+	ghost, ok := r.ghosts[page]
+	if !ok {
+		r.insert(r.t1, frameID, page)
+		r.trimGhosts()
+		return
+	}
+
+	if r.ghostIn[page] == r.b1 {
+		// t1 was too small to keep the page
+		r.p += maxInt(r.b2.Len()/r.b1.Len(), 1)
+		if r.p > r.capacity {
+			r.p = r.capacity
+		}
+		r.b1.Remove(ghost)
+	} else {
+		// t2 was too small to keep the page
+		r.p -= maxInt(r.b1.Len()/r.b2.Len(), 1)
+		if r.p < 0 {
+			r.p = 0
+		}
+		r.b2.Remove(ghost)
+	}
+	delete(r.ghosts, page)
+	delete(r.ghostIn, page)
+	r.insert(r.t2, frameID, page)
+}
+
+func (r *arcReplacer) SetEvictable(frameID uint64, evictable bool) {
+	if _, ok := r.lists[frameID]; ok {
+		r.evictable[frameID] = evictable
+	}
+}
+
+func (r *arcReplacer) Evict() (uint64, bool) {
+	from, ghosts := r.t2, r.b2
+	if r.t1.Len() > 0 && r.t1.Len() > r.p {
+		from, ghosts = r.t1, r.b1
+	}
+	e := lastEvictable(from, r.evictable)
+	if e == nil {
+		// every page of the preferred list is pinned, fall back to the other one
+		if from == r.t1 {
+			from, ghosts = r.t2, r.b2
+		} else {
+			from, ghosts = r.t1, r.b1
+		}
+		e = lastEvictable(from, r.evictable)
+	}
+	if e == nil {
+		return 0, false
+	}
+
+	frameID := e.Value.(uint64)
+	page := r.pages[frameID]
+	r.Remove(frameID)
+	r.ghosts[page] = ghosts.PushFront(page)
+	r.ghostIn[page] = ghosts
+	r.trimGhosts()
+	return frameID, true
+}
+
+func (r *arcReplacer) Remove(frameID uint64) {
+	l, ok := r.lists[frameID]
+	if !ok {
+		return
+	}
+	l.Remove(r.elements[frameID])
+	delete(r.elements, frameID)
+	delete(r.lists, frameID)
+	delete(r.pages, frameID)
+	delete(r.evictable, frameID)
+}
+
+/*
+insert puts the frame at the most recently used end of the list l, frames start out pinned
+*/
+func (r *arcReplacer) insert(l *list.List, frameID uint64, page PageKey) {
+	r.elements[frameID] = l.PushFront(frameID)
+	r.lists[frameID] = l
+	r.pages[frameID] = page
+}
+
+/*
+trimGhosts limits t1 and b1 together to the capacity and all four lists together to twice the capacity
+*/
+func (r *arcReplacer) trimGhosts() {
+	for r.b1.Len() > 0 && r.t1.Len()+r.b1.Len() > r.capacity {
+		r.forget(r.b1)
+	}
+	for r.b2.Len() > 0 && r.t1.Len()+r.t2.Len()+r.b1.Len()+r.b2.Len() > 2*r.capacity {
+		r.forget(r.b2)
+	}
+}
+
+/*
+forget drops the oldest page of the ghost list
+*/
+func (r *arcReplacer) forget(ghosts *list.List) {
+	page := ghosts.Remove(ghosts.Back()).(PageKey)
+	delete(r.ghosts, page)
+	delete(r.ghostIn, page)
+}
+
+func maxInt(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -2,9 +2,13 @@ package src
 
 import (
 	"errors"
-	"reflect"
 )
 
+/*
+errKeyNotFound is returned by traverse when the leaf responsible for the key does not contain it
+*/
+var errKeyNotFound = errors.New("key not found on leave level")
+
 /*
 IBTree Interface that defines the basic functionality of the B tree
 */
@@ -23,65 +27,131 @@ BTree is the type definition of a BTree and implements the get, push and getRang
 type BTree struct {
 	Name       string //defines the filename of the BTree for loading
 	RootPageId uint64
+	Height     int // number of levels below the root, the leaves are on this level
 	Manager    *BufferManager
 }
 
 /*
-Get fetches the value out of the index
+node is a page of the tree, either a copy in a Page or a PageView into the mapping of the file
+*/
+type node interface {
+	Key(i int) uint64
+	Value(i int) uint64
+}
+
+/*
+nodeKeys is the number of keys of a node
+*/
+const nodeKeys = len(Page{}.Keys)
+
+/*
+Get fetches the value out of the index. The pages of a mapped file are read through views, see WithMmap.
 */
 func (bm *BTree) Get(key uint64) (uint64, error) {
-	_, value, err := bm.traverse(key, 0, bm.RootPageId)
+	if bm.Manager.isMapped(bm.Name) {
+		return bm.getMapped(key)
+	}
+	leaf, value, err := bm.traverse(key, 0, bm.RootPageId)
+	if err == nil || err == errKeyNotFound {
+		_ = leaf.Release()
+	}
 	return value, err
 }
 
-func (bm *BTree) traverse(key uint64, currentLevel int, nextPageId uint64) (uint64, uint64, error) {
-	id, err := bm.Manager.Pin(bm.Name, nextPageId)
+/*
+getMapped walks down to the leaf through views of the pages, nothing is copied into the buffer
+*/
+func (bm *BTree) getMapped(key uint64) (uint64, error) {
+	pageInFile := bm.RootPageId
+	for currentLevel := 0; ; currentLevel++ {
+		view, err := bm.Manager.PinView(bm.Name, pageInFile)
+		if err != nil {
+			return 0, err
+		}
+		next, err := bm.search(view, key, currentLevel)
+		_ = view.Release()
+		if err != nil || currentLevel == bm.Height {
+			return next, err
+		}
+		pageInFile = next
+	}
+}
+
+/*
+traverse walks down to the leaf responsible for key and returns its handle together with the value of the key.
+The leaf is still pinned when it is returned without error or with errKeyNotFound, the caller has to release it.
+Every inner page is released on the way down.
+*/
+func (bm *BTree) traverse(key uint64, currentLevel int, nextPageId uint64) (*PageHandle, uint64, error) {
+	handle, err := bm.Manager.PinHandle(bm.Name, nextPageId)
+
+	if err != nil {
+		return nil, 0, err
+	}
 
+	page, err := handle.Page()
 	if err != nil {
-		return 0, 0, err
+		_ = handle.Release()
+		return nil, 0, err
 	}
 
-	page := bm.Manager.Pages[id]
+	next, err := bm.search(page, key, currentLevel)
+	if currentLevel == bm.Height && (err == nil || err == errKeyNotFound) {
+		return handle, next, err
+	}
+	_ = handle.Release()
+	if err != nil {
+		return nil, 0, err
+	}
+	return bm.traverse(key, currentLevel+1, next)
+}
 
-	for i := 0; i < len(page.Keys); i++ {
-		//fmt.Println(nextPageId)
-		// i limit the pages to 1 level deep for the sake of simplicity
-		if currentLevel == 1 {
+/*
+search looks for the key in a node on the given level. On the leaf level it returns the value of the key or
+errKeyNotFound, above it the page of the child that is responsible for the key.
+*/
+func (bm *BTree) search(n node, key uint64, currentLevel int) (uint64, error) {
+	for i := 0; i < nodeKeys; i++ {
+		if currentLevel == bm.Height {
 			// we are on leave level so we can start to look for exact key
-			if key == page.Keys[i] {
-				return id, page.Values[i], nil
-			} else if key > page.Keys[i] && page.Keys[i] == 0 {
-				return id, 0, errors.New("key not found on leave level")
-			} else if key > page.Keys[i] {
+			if key == n.Key(i) {
+				return n.Value(i), nil
+			} else if key > n.Key(i) && n.Key(i) == 0 {
+				return 0, errKeyNotFound
+			} else if key > n.Key(i) {
 				continue
 			} else {
-				return id, 0, errors.New("key not found on leave level")
+				return 0, errKeyNotFound
 			}
-		} else if i == len(page.Keys)-1 {
+		} else if i == nodeKeys-1 {
 			// we have reached the end of the keys, take the right most path down the tree
-			return bm.traverse(key, currentLevel+1, page.Values[i+1])
-		} else if key > page.Keys[i] && page.Keys[i] != 0 {
+			return n.Value(i + 1), nil
+		} else if key > n.Key(i) && n.Key(i) != 0 {
 			// go one key to the right since we have not reached the end yet
 			continue
 		} else {
 			// traverse into the next page
-			return bm.traverse(key, currentLevel+1, page.Values[i])
+			return n.Value(i), nil
 		}
 	}
-	return 0, 0, errors.New("error in traversing")
+	return 0, errors.New("error in traversing")
 }
 
 func (bm *BTree) Push(key uint64, value uint64) error {
 	// traverse down to the node
-	pageId, _, err := bm.traverse(key, 0, bm.RootPageId)
+	leaf, _, err := bm.traverse(key, 0, bm.RootPageId)
 
-	if err != nil && !reflect.DeepEqual(err, errors.New("key not found on leave level")) {
+	if err != nil && err != errKeyNotFound {
 		// traverse will give that error back, but we use it as a return for that we want to see for now
 		return err
 	}
 
 	// insert the new value
-	page := bm.Manager.Pages[pageId]
+	page, err := leaf.Page()
+	if err != nil {
+		_ = leaf.Release()
+		return err
+	}
 
 	// we have fetched the page.
 	for i := 0; i < len(page.Keys); i++ {
@@ -96,16 +166,133 @@ func (bm *BTree) Push(key uint64, value uint64) error {
 			page.Values[i] = value
 			break
 		} else if key == page.Keys[i] {
+			_ = leaf.Release()
 			return errors.New("key already present on leave level, cannot insert into tree")
 		} else if key > page.Keys[i] {
 			continue
 		}
 	}
-	bm.Manager.Pages[pageId] = page
+	err = leaf.SetPage(page)
+	if err != nil {
+		_ = leaf.Release()
+		return err
+	}
 
-	return nil
+	return leaf.Release()
 }
 
+/*
+scanRingSize is the number of frames a range scan recycles
+*/
+const scanRingSize = 4
+
+/*
+GetRange returns the key value pairs with low <= key <= high. The tree is walked down every path that can
+contain keys in the range, the pages are read through a ScanRing so the scan does not evict the hot pages.
+The pages of a mapped file are read through views instead, see WithMmap.
+*/
 func (bm *BTree) GetRange(low uint64, high uint64) (map[uint64]uint64, error) {
-	return nil, nil
+	result := make(map[uint64]uint64)
+	if low > high {
+		return result, nil
+	}
+	// a mapped file is read through views, it needs no frames
+	var ring *ScanRing
+	var err error
+	if !bm.Manager.isMapped(bm.Name) {
+		// the leaves that are read ahead need frames of their own
+		ring, err = bm.Manager.NewScanRing(scanRingSize + bm.Manager.prefetchDepth)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = bm.collectRange(ring, low, high, 0, bm.RootPageId, result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+/*
+collectRange adds the pairs in the range below the given page to result. The page is only pinned while it is copied,
+without a ring it is read through a view that is held until its children have been collected.
+*/
+func (bm *BTree) collectRange(ring *ScanRing, low uint64, high uint64, currentLevel int, pageInFile uint64, result map[uint64]uint64) error {
+	var n node
+	if ring == nil {
+		view, err := bm.Manager.PinView(bm.Name, pageInFile)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = view.Release()
+		}()
+		n = view
+	} else {
+		id, err := ring.Pin(bm.Name, pageInFile)
+		if err != nil {
+			return err
+		}
+		page, err := bm.Manager.Page(id)
+		_ = bm.Manager.Unpin(id, false)
+		if err != nil {
+			return err
+		}
+		n = page
+	}
+
+	if currentLevel == bm.Height {
+		for i := 0; i < nodeKeys && n.Key(i) != 0; i++ {
+			if n.Key(i) >= low && n.Key(i) <= high {
+				result[n.Key(i)] = n.Value(i)
+			}
+		}
+		return nil
+	}
+
+	// child i holds the keys above Keys[i-1] up to Keys[i], the child behind the last key holds the rest
+	keyCount := 0
+	for keyCount < nodeKeys && n.Key(keyCount) != 0 {
+		keyCount++
+	}
+	var children []uint64
+	for i := 0; i <= keyCount; i++ {
+		if i > 0 && n.Key(i-1) >= high {
+			break
+		}
+		if i < keyCount && n.Key(i) < low {
+			continue
+		}
+		children = append(children, n.Value(i))
+	}
+
+	// the leaves are read ahead while the scan walks over them
+	depth := 0
+	if ring != nil && currentLevel+1 == bm.Height {
+		depth = bm.Manager.prefetchDepth
+	}
+	for i, child := range children {
+		if depth > 0 {
+			if i == 0 {
+				ring.Prefetch(bm.Name, children[1:minInt(1+depth, len(children))]...)
+			} else if i+depth < len(children) {
+				ring.Prefetch(bm.Name, children[i+depth])
+			}
+		}
+		err := bm.collectRange(ring, low, high, currentLevel+1, child, result)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+minInt returns the smaller of a and b
+*/
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
 }
@@ -2,6 +2,7 @@ package src_test
 
 import (
 	"DMDS25/src"
+	"os"
 	"testing"
 )
 
@@ -27,23 +28,34 @@ func (b MockBTree) GetRange(lowLimit uint64, highLimit uint64) (map[uint64]uint6
 	return myMap, nil
 }
 
-var MyBuffer1, _ = src.CreateNewBufferManager("./testFiles/", uint64(1024))
-var myLoader1 = src.Loader{}
-var tree1, _ = myLoader1.Load("tree1", MyBuffer1)
-
-var MyBuffer2, _ = src.CreateNewBufferManager("./testFiles/", uint64(1024))
-var myLoader2 = src.Loader{}
-var tree2, _ = myLoader2.Load("tree2", MyBuffer2)
-
-var MyBuffer3, _ = src.CreateNewBufferManager("./testFiles/", uint64(1024))
-var myLoader3 = src.Loader{}
-var tree3, _ = myLoader3.Load("tree3", MyBuffer3)
+/*
+loadTree loads the tree of the given file in testFiles with a BufferManager of its own that is closed with the test
+*/
+func loadTree(t *testing.T, name string, options ...src.Option) *src.BTree {
+	t.Helper()
+	myBuffer, err := src.CreateNewBufferManager("./testFiles/", uint64(1024), options...)
+	if err != nil {
+		t.Fatalf("error while initializing BufferManager: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = myBuffer.Close()
+	})
+	myLoader := src.Loader{}
+	tree, err := myLoader.Load(name, myBuffer)
+	if err != nil {
+		t.Fatalf("error while loading %s: %v", name, err)
+	}
+	return tree
+}
 
 func TestBTreeSetup(t *testing.T) {
-	MyBuffer, err := src.CreateNewBufferManager("./testFiles/", uint64(1024))
+	MyBuffer, err := src.CreateNewBufferManager("./testFiles/", uint64(1024), src.WithReadOnly(true))
 	if err != nil {
 		t.Fatalf("error while initializing BufferManager: %v", err)
 	}
+	defer func() {
+		_ = MyBuffer.Close()
+	}()
 	myLoader := src.Loader{}
 	_, err = myLoader.Load("tree1", MyBuffer)
 
@@ -53,6 +65,7 @@ func TestBTreeSetup(t *testing.T) {
 }
 
 func TestBTree1Get1(t *testing.T) {
+	tree1 := loadTree(t, "tree1", src.WithReadOnly(true))
 	result, err := tree1.Get(1)
 	if err != nil {
 		t.Errorf("tree1.get(1) return error %d", err)
@@ -63,6 +76,7 @@ func TestBTree1Get1(t *testing.T) {
 }
 
 func TestBTree1Get11(t *testing.T) {
+	tree1 := loadTree(t, "tree1", src.WithReadOnly(true))
 	result, err := tree1.Get(11)
 	if err != nil {
 		t.Errorf("tree1.get(11) return error %d", err)
@@ -73,6 +87,7 @@ func TestBTree1Get11(t *testing.T) {
 }
 
 func TestBTree2Get1(t *testing.T) {
+	tree2 := loadTree(t, "tree2", src.WithReadOnly(true))
 	result, err := tree2.Get(1)
 	if err != nil {
 		t.Errorf("tree2.get(1) return error %d", err)
@@ -83,6 +98,7 @@ func TestBTree2Get1(t *testing.T) {
 }
 
 func TestBTree2Get11(t *testing.T) {
+	tree2 := loadTree(t, "tree2", src.WithReadOnly(true))
 	result, err := tree2.Get(11)
 	if err != nil {
 		t.Errorf("tree2.get(11) return error %d", err)
@@ -93,6 +109,7 @@ func TestBTree2Get11(t *testing.T) {
 }
 
 func TestBTree2Get15(t *testing.T) {
+	tree2 := loadTree(t, "tree2", src.WithReadOnly(true))
 	result, err := tree2.Get(15)
 	if err != nil {
 		t.Errorf("tree2.get(15) return error %d", err)
@@ -103,6 +120,7 @@ func TestBTree2Get15(t *testing.T) {
 }
 
 func TestBTree2Get21(t *testing.T) {
+	tree2 := loadTree(t, "tree2", src.WithReadOnly(true))
 	result, err := tree2.Get(21)
 	if err != nil {
 		t.Errorf("tree2.get(21) return error %d", err)
@@ -113,6 +131,7 @@ func TestBTree2Get21(t *testing.T) {
 }
 
 func TestBTree2GetNonExistentKey(t *testing.T) {
+	tree2 := loadTree(t, "tree2", src.WithReadOnly(true))
 	_, err := tree2.Get(100)
 	if err == nil {
 		t.Errorf("tree2.get(100) did not return an error %d", err)
@@ -120,6 +139,7 @@ func TestBTree2GetNonExistentKey(t *testing.T) {
 }
 
 func TestBTree2Get24(t *testing.T) {
+	tree2 := loadTree(t, "tree2", src.WithReadOnly(true))
 	result, err := tree2.Get(24)
 	if err != nil {
 		t.Errorf("tree2.get(24) return error %d", err)
@@ -130,6 +150,21 @@ func TestBTree2Get24(t *testing.T) {
 }
 
 func TestBTreeInsertAndFetch(t *testing.T) {
+	// the insert is written back when the BufferManager is closed, so it goes into a copy of the fixture
+	original, err := os.ReadFile("./testFiles/tree3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile("./testFiles/treeForInsert", original, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// registered before the BufferManager so the copy is removed after it has been closed
+	t.Cleanup(func() {
+		_ = os.Remove("./testFiles/treeForInsert")
+	})
+	tree3 := loadTree(t, "treeForInsert")
+
 	result, err := tree3.Get(15)
 	if err != nil {
 		t.Errorf("tree3.get(15) return error %d", err)
@@ -193,3 +228,114 @@ func TestIBTreeInsertAndFetchRange(t *testing.T) {
 		}
 	}
 }
+
+func TestBTreePushAndFlush(t *testing.T) {
+	err := os.WriteFile("./testFiles/treeForFlush", []byte("10;;;;;;1;2;;;;;\n1;;;;;;2;;;;;;\n11;;;;;;12;;;;;;"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.Remove("./testFiles/treeForFlush")
+	}()
+
+	myBuffer, _ := src.CreateNewBufferManager("./testFiles/", uint64(1024))
+	myLoader := src.Loader{}
+	tree, _ := myLoader.Load("treeForFlush", myBuffer)
+
+	err = tree.Push(13, 14)
+	if err != nil {
+		t.Fatalf("tree.Push(13) return error %d", err)
+	}
+	err = myBuffer.Flush()
+	if err != nil {
+		t.Fatalf("Flush return error %d", err)
+	}
+
+	_ = myBuffer.Close()
+	restartedTree := loadTree(t, "treeForFlush")
+	result, err := restartedTree.Get(13)
+	if err != nil {
+		t.Errorf("tree.get(13) return error %d after flush", err)
+	}
+	if result != 14 {
+		t.Errorf("tree.get(13) returned %d instead of 14 after flush", result)
+	}
+}
+
+func TestBTreeSharedBufferManager(t *testing.T) {
+	sharedBuffer, _ := src.CreateNewBufferManager("./testFiles/", uint64(1024), src.WithReadOnly(true))
+	defer func() {
+		_ = sharedBuffer.Close()
+	}()
+	myLoader := src.Loader{}
+	sharedTree1, err := myLoader.Load("tree1", sharedBuffer)
+	if err != nil {
+		t.Fatalf("error while loading tree1: %v", err)
+	}
+	sharedTree2, err := myLoader.Load("tree2", sharedBuffer)
+	if err != nil {
+		t.Fatalf("error while loading tree2: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		result, err := sharedTree1.Get(11)
+		if err != nil || result != 12 {
+			t.Errorf("tree1.get(11) returned %d, %v instead of 12 with a shared buffer", result, err)
+		}
+		result, err = sharedTree2.Get(21)
+		if err != nil || result != 22 {
+			t.Errorf("tree2.get(21) returned %d, %v instead of 22 with a shared buffer", result, err)
+		}
+		_, err = sharedTree1.Get(21)
+		if err == nil {
+			t.Errorf("tree1.get(21) found a key that only exists in tree2")
+		}
+	}
+}
+
+func TestBTreeGetRange(t *testing.T) {
+	err := os.WriteFile("./testFiles/treeForRange", []byte("10;20;;;;;1;2;3;;;;\n1;5;10;;;;2;6;11;;;;\n11;15;;;;;12;16;;;;;\n21;30;;;;;22;31;;;;;"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.Remove("./testFiles/treeForRange")
+	}()
+
+	myBuffer, _ := src.CreateNewBufferManager("./testFiles/", uint64(1024))
+	defer func() {
+		_ = myBuffer.Close()
+	}()
+	myLoader := src.Loader{}
+	tree, err := myLoader.Load("treeForRange", myBuffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, testCase := range []struct {
+		low, high uint64
+		expected  map[uint64]uint64
+	}{
+		{5, 21, map[uint64]uint64{5: 6, 10: 11, 11: 12, 15: 16, 21: 22}},
+		{10, 10, map[uint64]uint64{10: 11}},
+		{0, ^uint64(0), map[uint64]uint64{1: 2, 5: 6, 10: 11, 11: 12, 15: 16, 21: 22, 30: 31}},
+		{31, 40, map[uint64]uint64{}},
+		{20, 10, map[uint64]uint64{}},
+	} {
+		result, err := tree.GetRange(testCase.low, testCase.high)
+		if err != nil {
+			t.Fatalf("tree.GetRange(%d, %d) returned error %v", testCase.low, testCase.high, err)
+		}
+		if len(result) != len(testCase.expected) {
+			t.Fatalf("tree.GetRange(%d, %d) returned %v instead of %v", testCase.low, testCase.high, result, testCase.expected)
+		}
+		for k, v := range testCase.expected {
+			if result[k] != v {
+				t.Fatalf("tree.GetRange(%d, %d) returned %v instead of %v", testCase.low, testCase.high, result, testCase.expected)
+			}
+		}
+	}
+	if pinned := myBuffer.Stats().PinnedPages; pinned != 0 {
+		t.Fatalf("%d pages are still pinned after the range scans", pinned)
+	}
+}
@@ -1,12 +1,13 @@
 package src
 
 import (
+	"context"
+	"crypto/aes"
 	"errors"
-	"log"
-	"os"
-	"reflect"
-	"strconv"
-	"strings"
+	"fmt"
+	"io"
+	"sync"
+	"time"
 )
 
 /*
@@ -29,254 +30,645 @@ type IBufferManager interface {
 	Delete(fileID string) error
 
 	/*
-		close the opened b tree file
+		close all opened b tree files
 	*/
 	Close() error
 
 	/*
 		pin a given page from the btree to memory
 	*/
-	Pin(fileID string) (uint64, error)
+	Pin(fileID string, pageInFile uint64) (uint64, error)
 
 	/*
-		unpin a given page from the btree to memory
+		unpin a given page from the btree to memory, dirty marks it as modified
 	*/
-	Unpin(pageID uint64) error
+	Unpin(pageID uint64, dirty bool) error
 }
 
-type BufferManager struct {
-	Pages        [10]Page
-	dir          string
-	memory       uint64
-	tmpFileData  []byte
-	openFileName string
-	PageMap      map[uint64]uint64 // key is pageInFile, value is pageID in Buffer Manager
-}
+var _ IBufferManager = (*BufferManager)(nil)
 
-func CreateNewBufferManager(dir string, memory uint64) (*BufferManager, error) {
-	mapping := make(map[uint64]uint64)
-	return &(BufferManager{dir: dir, memory: memory, PageMap: mapping}), nil
+type BufferManager struct {
+	mu            sync.Mutex            // latch of the page table, guards the frames and the open files, see Latch.go
+	Pages         []Page                // concurrent users access the pages through Page and SetPage
+	latches       []*sync.RWMutex       // latch of the page in the frame with the same index
+	loads         map[PageKey]*pageLoad // pages that are being read from disk
+	freed         chan struct{}         // closed when a frame can be used for another page, see PinContext
+	prefetchDepth int                   // number of pages a range scan reads ahead, see Prefetch.go
+	prefetching   sync.WaitGroup        // running read aheads
+	frames        []frame               // bookkeeping for the page with the same index in Pages
+	policy        ReplacementPolicy
+	replacer      Replacer // chooses the frame to evict when no frame is free
+	stats         Stats    // counters since the BufferManager has been created, see Stats.go
+	dir           string
+	storage       Storage // holds the tree files, see WithStorage
+	memory        uint64
+	sync          bool                     // sync every write to disk, see WithSync
+	compress      bool                     // create new files with compressed pages, see Compression.go
+	encryptionKey []byte                   // create new files with encrypted pages and read encrypted files, see Encryption.go
+	readOnly      bool                     // lock the files shared and reject changes, see Lock.go
+	mmap          bool                     // map binary files into memory, see Mmap.go
+	locks         map[string]io.Closer     // the lock of every file that has been opened
+	handles       map[*PageHandle]struct{} // handles that have not been released, only tracked in debug builds
+	files         map[string]*openFile     // the open handle of each tree file, see FileTable.go
+	maxOpenFiles  int                      // number of handles kept open at most
+	fileTick      uint64                   // incremented on every file access to find the least recently used handle
+	PageMap       map[PageKey]uint64       // key is the file and pageInFile, value is pageID in Buffer Manager
+	dirtyCount    int                      // number of dirty frames
+	flusher       flusher                  // background writing of dirty pages, see Flusher.go
 }
 
-func (bm *BufferManager) Open(fileID string) error {
-	dat, err := os.ReadFile(bm.dir + fileID)
-	bm.tmpFileData = dat
-	bm.openFileName = fileID
-	return err
+/*
+frame holds the state of one slot in the Pages of the BufferManager
+*/
+type frame struct {
+	used       bool // the slot currently holds a page
+	pinCount   int  // number of pins that have not been unpinned yet, pinned pages are never evicted
+	dirty      bool // the page has been modified and has to be written back before it is evicted
+	loading    bool // the page is being read from disk, the frame is reserved for it
+	prefetched bool // the page has been read ahead and not been used yet, it is evicted first
 }
 
-func (bm *BufferManager) Close() error {
-	if bm.tmpFileData == nil {
-		return errors.New("no file to close")
+/*
+Option configures a BufferManager when it is created
+*/
+type Option func(bm *BufferManager)
 
-	} else {
-		bm.tmpFileData = nil
-		bm.openFileName = ""
-		return nil
+/*
+WithMaxOpenFiles limits the number of tree files that are kept open at the same time, the default is 16.
+The least recently used file is closed when another one has to be opened. Its cached pages and its lock are kept,
+it is opened again when one of its pages has to be read or written.
+*/
+func WithMaxOpenFiles(maxOpenFiles int) Option {
+	return func(bm *BufferManager) {
+		bm.maxOpenFiles = maxOpenFiles
 	}
 }
 
-func (bm *BufferManager) Delete(fileID string) error {
-	dat, _ := os.ReadFile(bm.dir + fileID)
+/*
+WithStorage keeps the tree files in the given Storage instead of the directory of the BufferManager
+*/
+func WithStorage(storage Storage) Option {
+	return func(bm *BufferManager) {
+		bm.storage = storage
+	}
+}
 
-	if dat != nil {
-		return os.Remove(bm.dir + fileID)
+/*
+WithSync makes every write of a page reach the disk before it returns, so flushed pages survive a power loss
+and a power loss never leaves a torn page behind. Without it the operating system decides when and in which order
+the data is written, only a crash of the process is guaranteed not to leave a torn page behind.
+*/
+func WithSync(sync bool) Option {
+	return func(bm *BufferManager) {
+		bm.sync = sync
 	}
-	return errors.New("no file to delete")
 }
 
-func (bm *BufferManager) Pin(fileID string, pageInFile uint64) (uint64, error) {
+/*
+WithReplacementPolicy selects the policy used to choose which page is evicted, the default is PolicyLRU
+*/
+func WithReplacementPolicy(policy ReplacementPolicy) Option {
+	return func(bm *BufferManager) {
+		bm.policy = policy
+	}
+}
 
-	for key, value := range bm.PageMap {
-		if key == pageInFile {
-			return value, nil
+/*
+CreateNewBufferManager creates a BufferManager for the files in dir.
+The pool gets as many frames as pages of PageSize fit into memory bytes.
+*/
+func CreateNewBufferManager(dir string, memory uint64, options ...Option) (*BufferManager, error) {
+	frameCount, err := framesFor(memory)
+	if err != nil {
+		return nil, err
+	}
+	mapping := make(map[PageKey]uint64)
+	bm := &(BufferManager{dir: dir, memory: memory, PageMap: mapping, policy: PolicyLRU, maxOpenFiles: 16})
+	for _, option := range options {
+		option(bm)
+	}
+	if bm.storage == nil {
+		bm.storage = NewDiskStorage(dir)
+	}
+	if bm.prefetchDepth < 0 {
+		return nil, fmt.Errorf("prefetch depth cannot be negative, got %d", bm.prefetchDepth)
+	}
+	if bm.encryptionKey != nil {
+		_, err = aes.NewCipher(bm.encryptionKey)
+		if err != nil {
+			return nil, fmt.Errorf("the encryption key cannot be used: %v", err)
 		}
 	}
-	err := bm.Open(fileID)
+	if bm.mmap && !bm.readOnly {
+		return nil, errors.New("WithMmap needs WithReadOnly, a mapped file must not be written")
+	}
+	if bm.maxOpenFiles < 1 {
+		return nil, fmt.Errorf("at least one file has to be kept open, got %d", bm.maxOpenFiles)
+	}
+	err = bm.flusher.validate()
+	if err != nil {
+		return nil, err
+	}
+	bm.files = make(map[string]*openFile)
+	bm.locks = make(map[string]io.Closer)
+	bm.handles = make(map[*PageHandle]struct{})
+
+	bm.stats.PinWait = newHistogram(pinWaitBounds)
+	bm.Pages = make([]Page, frameCount)
+	bm.frames = make([]frame, frameCount)
+	bm.latches = newLatches(frameCount)
+	bm.loads = make(map[PageKey]*pageLoad)
+	replacer, err := NewReplacer(bm.policy, frameCount)
 	if err != nil {
-		return 0, err
+		return nil, err
+	}
+	bm.replacer = replacer
+	bm.startFlusher()
+	return bm, nil
+}
+
+/*
+framesFor returns the number of frames that fit into the memory budget
+*/
+func framesFor(memory uint64) (int, error) {
+	if memory < PageSize {
+		return 0, fmt.Errorf("memory budget of %d bytes is smaller than one page of %d bytes", memory, PageSize)
 	}
-	err = bm.Open(fileID)
+	return int(memory / PageSize), nil
+}
+
+/*
+Resize changes the memory budget of the pool at runtime. When the pool shrinks the pages in the
+frames that are given up are evicted, dirty ones are written back first. If one of them is pinned
+Resize fails without changing the pool. The Replacer starts over with the pages that stay in the pool.
+*/
+func (bm *BufferManager) Resize(memory uint64) error {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	frameCount, err := framesFor(memory)
 	if err != nil {
-		return 0, err
+		return err
 	}
-	if bm.tmpFileData == nil {
-		return 0, errors.New("no tmpFileData found")
+	for i := frameCount; i < len(bm.frames); i++ {
+		if bm.frames[i].pinCount > 0 {
+			return fmt.Errorf("cannot shrink the buffer, the page in frame %d is pinned", i)
+		}
 	}
 
-	for i := uint64(0); i < uint64(len(bm.Pages)); i++ {
-		if !reflect.DeepEqual(bm.Pages[i], Page{}) {
-			// page is not free
+	for i := frameCount; i < len(bm.frames); i++ {
+		pageID := uint64(i)
+		if !bm.frames[pageID].used {
 			continue
-		} else if i == uint64(len(bm.Pages)-1) && !reflect.DeepEqual(bm.Pages[i], Page{}) {
-			return 0, errors.New("buffer manager is full")
-		} else {
-			//TODO deserialize the page from disk
-			page, err := bm.deserialize(pageInFile)
+		}
+		if bm.frames[pageID].dirty {
+			err = bm.serialize(pageID)
 			if err != nil {
-				return 0, err
+				return err
 			}
-			bm.Pages[i] = page
-			_ = bm.Close()
-
-			// adding the page to the mapping
-			bm.PageMap[pageInFile] = i
+		}
+		bm.dropFrame(pageID)
+		bm.stats.Evictions++
+	}
 
-			return i, nil
+	replacer, err := NewReplacer(bm.policy, frameCount)
+	if err != nil {
+		return err
+	}
+	// the pinned pages move to the new slice, nobody may access them in the meantime
+	held := bm.latches
+	for _, latch := range held {
+		latch.Lock()
+	}
+	if frameCount < len(bm.frames) {
+		bm.Pages = bm.Pages[:frameCount]
+		bm.frames = bm.frames[:frameCount]
+		bm.latches = bm.latches[:frameCount]
+	} else {
+		bm.Pages = append(bm.Pages, make([]Page, frameCount-len(bm.Pages))...)
+		bm.frames = append(bm.frames, make([]frame, frameCount-len(bm.frames))...)
+		bm.latches = append(bm.latches, newLatches(frameCount-len(bm.latches))...)
+	}
+	for _, latch := range held {
+		latch.Unlock()
+	}
+	for i := 0; i < frameCount; i++ {
+		if bm.frames[i].used && !bm.frames[i].loading {
+			replacer.RecordAccess(uint64(i), bm.Pages[i].key())
+			replacer.SetEvictable(uint64(i), bm.frames[i].pinCount == 0)
 		}
 	}
-	return 0, errors.New("no file to pin")
+	bm.replacer = replacer
+	bm.memory = memory
+	bm.signalFrameFreed()
+	return nil
 }
 
-func (bm *BufferManager) Unpin(pageID uint64) error {
-
-	if reflect.DeepEqual(bm.Pages[pageID], Page{}) {
-		return errors.New("there is no page to depin at this Id")
+func (bm *BufferManager) Delete(fileID string) error {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	if bm.readOnly {
+		return ErrReadOnly
 	}
-	bm.Pages[pageID] = Page{}
-	err := bm.RemoveMapEntryByValue(pageID)
+	// the cached pages of the file must not be written back after it is gone
+	err := bm.dropFile(fileID)
+	if err != nil {
+		return err
+	}
+	if _, ok := bm.files[fileID]; ok {
+		_ = bm.closeFile(fileID)
+	}
+	exists, err := bm.storage.Exists(fileID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errors.New("no file to delete")
+	}
+	// the file must not be in use elsewhere, releasing the lock afterwards removes the lock file as well
+	err = bm.lock(fileID)
+	if err != nil {
+		return err
+	}
+	// the free list and the double write buffer belong to the file, missing ones are not an error
+	_ = removeIfExists(bm.storage, freeListName(fileID))
+	_ = removeIfExists(bm.storage, doubleWriteName(fileID))
+	err = bm.storage.Remove(fileID)
+	unlockErr := bm.unlock(fileID)
 	if err != nil {
 		return err
 	}
+	return unlockErr
+}
 
-	return nil
+/*
+ErrNoFreeFrame is returned by Pin when every frame holds a pinned page
+*/
+var ErrNoFreeFrame = errors.New("buffer manager is full, every page is pinned")
+
+/*
+Pin loads the page pageInFile of the given file into a frame and returns the id of the frame.
+Each Pin increments the pin count of the frame and has to be matched by an Unpin.
+If no frame is free the Replacer chooses an unpinned page to evict, it is written back if it is dirty.
+Concurrent pins of a page that is not in the buffer read it from disk only once.
+If every frame is pinned ErrNoFreeFrame is returned at once, PinContext waits for a frame instead.
+*/
+func (bm *BufferManager) Pin(fileID string, pageInFile uint64) (uint64, error) {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	pageID, waited, err := bm.pin(PageKey{File: fileID, Page: pageInFile}, nil)
+	bm.stats.PinWait.observe(waited)
+	return pageID, err
 }
 
-func (bm *BufferManager) RemoveMapEntryByValue(value uint64) error {
-	for key, val := range bm.PageMap {
-		if val == value {
-			delete(bm.PageMap, key)
-			return nil
+/*
+PinContext pins the page like Pin but waits for a frame to be unpinned while every frame is pinned.
+It gives up when ctx is done and returns how long it has waited for a frame.
+*/
+func (bm *BufferManager) PinContext(ctx context.Context, fileID string, pageInFile uint64) (uint64, time.Duration, error) {
+	var waited time.Duration
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	for {
+		if err := ctx.Err(); err != nil {
+			bm.stats.PinWait.observe(waited)
+			return 0, waited, err
+		}
+		pageID, loadWait, err := bm.pin(PageKey{File: fileID, Page: pageInFile}, nil)
+		waited += loadWait
+		if err != ErrNoFreeFrame {
+			bm.stats.PinWait.observe(waited)
+			return pageID, waited, err
+		}
+
+		freed := bm.frameFreed()
+		start := time.Now()
+		bm.mu.Unlock()
+		select {
+		case <-ctx.Done():
+		case <-freed:
 		}
+		bm.mu.Lock()
+		waited += time.Since(start)
 	}
-	return errors.New("no page with this Id found to remove from map")
 }
 
-func (bm *BufferManager) GetMapEntryKeyByValue(value uint64) (uint64, error) {
-	for key, val := range bm.PageMap {
-		if val == value {
-			return key, nil
+/*
+pin pins the page of key and returns how long it waited for a concurrent read of the page.
+A page that is not in the buffer is read into a frame of the ring if one is given.
+The latch of the page table has to be held.
+*/
+func (bm *BufferManager) pin(key PageKey, ring *ScanRing) (uint64, time.Duration, error) {
+	var waited time.Duration
+	for {
+		if pageID, ok := bm.PageMap[key]; ok {
+			bm.stats.Hits++
+			bm.stats.Pins++
+			prefetched := bm.frames[pageID].prefetched
+			bm.pinFrame(pageID)
+			if prefetched {
+				bm.stats.PrefetchHits++
+				// a scan keeps its pages at low priority, they are not used again once it has moved on
+				bm.frames[pageID].prefetched = ring != nil
+			}
+			return pageID, waited, nil
+		}
+		load, ok := bm.loads[key]
+		if !ok {
+			break
+		}
+		// another goroutine is reading the page, the page may already be evicted again once it is done
+		start := time.Now()
+		bm.mu.Unlock()
+		<-load.done
+		bm.mu.Lock()
+		waited += time.Since(start)
+		if load.err != nil {
+			return 0, waited, load.err
 		}
 	}
-	return 0, errors.New("no page with this Id found to remove from map")
+	pageID, err := bm.load(key, ring)
+	if err != nil {
+		return 0, waited, err
+	}
+	bm.stats.Misses++
+	bm.stats.Pins++
+	bm.stats.BytesRead += PageSize
+	return pageID, waited, nil
 }
 
 /*
-deserialize the byte values currently present in the tmpFileData or throw an error
+frameFreed returns a channel that is closed as soon as a frame can be used for another page
 */
-func (bm *BufferManager) deserialize(pageInFile uint64) (Page, error) {
-
-	pageRowString := strings.Split(string(bm.tmpFileData), "\n")[pageInFile]
-	stringArray := strings.Split(pageRowString, ";")
-
-	if bm.tmpFileData == nil || bm.openFileName == "" {
-		if len(stringArray) != 13 {
-			return Page{}, errors.New("deserialization failed, there is no open file")
-		}
+func (bm *BufferManager) frameFreed() <-chan struct{} {
+	if bm.freed == nil {
+		bm.freed = make(chan struct{})
 	}
+	return bm.freed
+}
 
-	if len(stringArray) != 13 {
-		return Page{}, errors.New("deserialization failed, the row does not contain 13 elements")
+/*
+signalFrameFreed wakes up the pins that wait for a frame
+*/
+func (bm *BufferManager) signalFrameFreed() {
+	if bm.freed != nil {
+		close(bm.freed)
+		bm.freed = nil
 	}
+}
 
-	// init the tmp arrays
-	keys := [6]uint64{}
-	values := [7]uint64{}
+/*
+Unpin releases one pin of the page in the given frame, dirty has to be set if the page has been modified.
+Once the pin count drops to zero the page stays in the buffer but may be evicted.
+A dirty page is only written to disk when it is evicted or flushed.
+*/
+func (bm *BufferManager) Unpin(pageID uint64, dirty bool) error {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	return bm.unpin(pageID, dirty)
+}
 
-	// read the keys
-	for i := 0; i < 6; i++ {
-		if stringArray[i] != "" {
-			keys[i], _ = strconv.ParseUint(stringArray[i], 10, 64)
-		} else {
-			values[i] = 0
+/*
+unpin releases one pin of the page in the given frame, once enough pages are dirty the background flusher is woken up
+*/
+func (bm *BufferManager) unpin(pageID uint64, dirty bool) error {
+	if pageID >= uint64(len(bm.Pages)) || !bm.frames[pageID].used || bm.frames[pageID].loading {
+		return errors.New("there is no page to depin at this Id")
+	}
+	if bm.frames[pageID].pinCount == 0 {
+		return errors.New("the page at this Id is not pinned")
+	}
+	bm.frames[pageID].pinCount--
+	if dirty && bm.readOnly {
+		// the modification is discarded, the page is read again when it is pinned the next time
+		if bm.frames[pageID].pinCount == 0 {
+			bm.dropFrame(pageID)
 		}
+		return ErrReadOnly
+	}
+	if dirty {
+		bm.setDirty(pageID, true)
+		bm.flusher.notify(bm.dirtyCount)
 	}
+	if bm.frames[pageID].pinCount == 0 {
+		bm.replacer.SetEvictable(pageID, true)
+		bm.signalFrameFreed()
+	}
+	return nil
+}
 
-	// read the values
-	offset := 6
-	for i := 0; i < 7; i++ {
-		index := offset + i
-		if stringArray[index] != "" {
-			values[i], _ = strconv.ParseUint(stringArray[index], 10, 64)
-		} else {
-			values[i] = 0
-		}
+/*
+UnpinPage releases one pin of the page pageInFile of the given file, like Unpin does for its frame
+*/
+func (bm *BufferManager) UnpinPage(fileID string, pageInFile uint64, dirty bool) error {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	pageID, ok := bm.PageMap[PageKey{File: fileID, Page: pageInFile}]
+	if !ok {
+		return fmt.Errorf("page %d of %s is not in the buffer", pageInFile, fileID)
 	}
-	return Page{Keys: keys, Values: values, pageId: pageInFile, Name: bm.openFileName}, nil
+	return bm.unpin(pageID, dirty)
 }
 
 /*
-serialize the given page and write it to disk
+HitRatio returns the share of pins that found their page in the buffer, 0 if nothing has been pinned yet
 */
-func (bm *BufferManager) serialize(pageID uint64) error {
-	page := bm.Pages[pageID]
-	_ = bm.Open(bm.dir + page.Name)
-	var outputString = ""
+func (bm *BufferManager) HitRatio() float64 {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	if bm.stats.Hits+bm.stats.Misses == 0 {
+		return 0
+	}
+	return float64(bm.stats.Hits) / float64(bm.stats.Hits+bm.stats.Misses)
+}
 
-	pageRowStrings := strings.Split(string(bm.tmpFileData), "\n")
+/*
+pinFrame increments the pin count of the frame and reports the access to the Replacer
+*/
+func (bm *BufferManager) pinFrame(pageID uint64) {
+	bm.frames[pageID].pinCount++
+	bm.replacer.RecordAccess(pageID, bm.Pages[pageID].key())
+	bm.replacer.SetEvictable(pageID, false)
+}
 
-	for rowId := uint64(0); rowId < uint64(len(pageRowStrings)); rowId++ {
-		pageInFile, err := bm.GetMapEntryKeyByValue(pageID)
-		if err != nil {
-			return err
+/*
+freeFrame returns the id of an empty frame. If every frame is in use a prefetched page that has not been
+used is evicted first, otherwise the Replacer chooses an unpinned page to evict. A dirty victim is written back first.
+*/
+func (bm *BufferManager) freeFrame() (uint64, error) {
+	for i := 0; i < len(bm.frames); i++ {
+		if !bm.frames[i].used {
+			return uint64(i), nil
 		}
-		if pageInFile == rowId {
-			for i := 0; i < len(page.Keys); i++ {
-				if tmpKey := page.Keys[i]; tmpKey != 0 {
-					outputString = outputString + strconv.FormatUint(uint64(page.Keys[i]), 10)
-				}
-				outputString = outputString + ";"
+	}
+	if pageID, ok := bm.prefetchVictim(); ok {
+		if bm.frames[pageID].dirty {
+			err := bm.serialize(pageID)
+			if err != nil {
+				return 0, err
 			}
+		}
+		bm.dropFrame(pageID)
+		bm.stats.Evictions++
+		return pageID, nil
+	}
 
-			for i := 0; i < len(page.Values); i++ {
-				if tmpValue := page.Values[i]; tmpValue != 0 {
-					outputString = outputString + strconv.FormatUint(uint64(page.Values[i]), 10)
-				}
-				if i == len(page.Values)-1 {
-					break
-				}
-				outputString = outputString + ";"
-			}
-			outputString = outputString + "\n"
-		} else {
-			outputString = outputString + pageRowStrings[rowId] + "\n"
+	pageID, ok := bm.replacer.Evict()
+	if !ok {
+		return 0, ErrNoFreeFrame
+	}
+	if bm.frames[pageID].dirty {
+		err := bm.serialize(pageID)
+		if err != nil {
+			// the victim stays in the buffer so the modification is not lost
+			bm.replacer.RecordAccess(pageID, bm.Pages[pageID].key())
+			bm.replacer.SetEvictable(pageID, true)
+			return 0, err
 		}
 	}
-	_ = bm.Close()
-	outputString = strings.TrimSuffix(outputString, "\n")
+	bm.dropFrame(pageID)
+	bm.stats.Evictions++
+	return pageID, nil
+}
 
-	file, err := os.OpenFile(bm.dir+page.Name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 777)
+/*
+dropFrame removes the page from the frame without writing it back
+*/
+func (bm *BufferManager) dropFrame(pageID uint64) {
+	bm.replacer.Remove(pageID)
+	bm.setDirty(pageID, false)
+	delete(bm.PageMap, bm.Pages[pageID].key())
+	bm.Pages[pageID] = Page{}
+	bm.frames[pageID] = frame{}
+	bm.signalFrameFreed()
+}
 
-	if err != nil {
-		log.Fatalf("An error occured while opening the file: %s", err)
+/*
+dropFile removes every page of the given file from the buffer without writing it back.
+Nothing is removed if one of the pages is still pinned.
+*/
+func (bm *BufferManager) dropFile(fileID string) error {
+	for key := range bm.loads {
+		if key.File == fileID {
+			return fmt.Errorf("page %d of %s is being loaded", key.Page, fileID)
+		}
+	}
+	for key, pageID := range bm.PageMap {
+		if key.File == fileID && bm.frames[pageID].pinCount > 0 {
+			return fmt.Errorf("page %d of %s is still pinned", key.Page, fileID)
+		}
 	}
+	for key, pageID := range bm.PageMap {
+		if key.File == fileID {
+			bm.dropFrame(pageID)
+		}
+	}
+	return nil
+}
 
-	err = os.Truncate(bm.dir+page.Name, 0)
+/*
+serialize writes the page in the given frame to its place on disk
+*/
+func (bm *BufferManager) serialize(pageID uint64) error {
+	page := bm.Pages[pageID]
+	file, err := bm.file(page.Name, false)
 	if err != nil {
-		log.Fatalf("An error occured while truncating file: %s", err)
+		return err
 	}
-	_, err = file.Write([]byte(outputString))
+	err = file.WritePage(page)
 	if err != nil {
-		log.Fatalf("An error occured while writing to file: %s", err)
+		return err
 	}
-
-	_ = bm.Close()
-
-	_ = file.Close()
+	bm.stats.WriteBacks++
+	bm.stats.BytesWritten += PageSize
 	return nil
 }
 
 /*
-Flush writes everthing to disk
+Flush writes every dirty page to disk.
+An error of the background flusher that has not been reported yet is returned if the flush itself succeeds.
 */
 func (bm *BufferManager) Flush() error {
-	for _, pageID := range bm.PageMap {
-		// key is the pageInFileId
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	err := bm.flush(func(key PageKey) bool { return true })
+	if err != nil {
+		return err
+	}
+	return bm.flusher.takeError()
+}
+
+/*
+FlushFile writes the dirty pages of the given file to disk
+*/
+func (bm *BufferManager) FlushFile(fileID string) error {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	return bm.flush(func(key PageKey) bool { return key.File == fileID })
+}
+
+/*
+setDirty marks the page in the given frame as modified or clean and keeps count of the dirty frames
+*/
+func (bm *BufferManager) setDirty(pageID uint64, dirty bool) {
+	if bm.frames[pageID].dirty == dirty {
+		return
+	}
+	bm.frames[pageID].dirty = dirty
+	if dirty {
+		bm.dirtyCount++
+	} else {
+		bm.dirtyCount--
+	}
+}
+
+/*
+flush writes the dirty pages whose key is selected, the pages of one file are written in one batch.
+The pages of a file that cannot be written stay dirty and the first error is returned.
+*/
+func (bm *BufferManager) flush(selected func(key PageKey) bool) error {
+	byFile := make(map[string][]uint64)
+	for key, pageID := range bm.PageMap {
+		// key is the file and pageInFile
 		// value is the pageId in the bm.Pages
-		err := bm.serialize(pageID)
-		if err != nil {
-			return err
+		if !selected(key) || !bm.frames[pageID].dirty {
+			continue
+		}
+		byFile[key.File] = append(byFile[key.File], pageID)
+	}
+
+	var firstErr error
+	for fileID, pageIDs := range byFile {
+		err := bm.writeBack(fileID, pageIDs)
+		if err != nil && firstErr == nil {
+			firstErr = err
 		}
 	}
+	return firstErr
+}
+
+/*
+writeBack writes the pages in the given frames to the file at once and marks them as clean
+*/
+func (bm *BufferManager) writeBack(fileID string, pageIDs []uint64) error {
+	file, err := bm.file(fileID, false)
+	if err != nil {
+		return err
+	}
+	pages := make([]Page, len(pageIDs))
+	for i, pageID := range pageIDs {
+		// pinned pages may be modified at the same time
+		bm.latches[pageID].RLock()
+		pages[i] = bm.Pages[pageID]
+		bm.latches[pageID].RUnlock()
+	}
+	err = file.WritePages(pages)
+	if err != nil {
+		return err
+	}
+	for _, pageID := range pageIDs {
+		bm.setDirty(pageID, false)
+	}
+	bm.stats.WriteBacks += uint64(len(pages))
+	bm.stats.BytesWritten += uint64(len(pages)) * PageSize
 	return nil
 }
@@ -1,8 +1,12 @@
 package src
 
 import (
+	"context"
+	"errors"
 	"os"
+	"strconv"
 	"testing"
+	"time"
 )
 
 /*
@@ -20,16 +24,42 @@ TestBufferManagerOpen Checks if we can open a file that exists without an error
 */
 func TestBufferManagerOpen(t *testing.T) {
 	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024))
-	file, err := os.Create("./testFile")
-	_ = file.Close()
+	createFileWithPages(t, "testFile", 1)
+	defer func() {
+		_ = myBuffer.Close()
+		_ = os.Remove("./testFile")
+	}()
+	err := myBuffer.Open("testFile")
 	if err != nil {
 		t.Fatal(err)
 	}
-	err = myBuffer.Open("testFile")
+}
+
+/*
+TestBufferManagerOpenEmptyFile checks that an empty file is rejected and not modified when it is opened for reading
+*/
+func TestBufferManagerOpenEmptyFile(t *testing.T) {
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024))
+	file, err := os.Create("./testFileEmpty")
+	_ = file.Close()
 	if err != nil {
 		t.Fatal(err)
 	}
-	_ = os.Remove("./testFile")
+	defer func() {
+		_ = myBuffer.Close()
+		_ = os.Remove("./testFileEmpty")
+	}()
+	err = myBuffer.Open("testFileEmpty")
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("opening an empty file returned %v", err)
+	}
+	_, err = myBuffer.Pin("testFileEmpty", 0)
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("pinning a page of an empty file returned %v", err)
+	}
+	if info, err := os.Stat("./testFileEmpty"); err != nil || info.Size() != 0 {
+		t.Errorf("the empty file has been modified: %v, %v", info, err)
+	}
 }
 
 /*
@@ -48,18 +78,48 @@ TestBufferManagerClose tests if we can close an open file properly
 */
 func TestBufferManagerClose(t *testing.T) {
 	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024))
-	file, err := os.Create("./testFileForClose")
-	_ = file.Close()
+	createFileWithPages(t, "testFileForClose", 1)
+	err := myBuffer.Open("testFileForClose")
 	if err != nil {
 		t.Fatal(err)
 	}
-	err = myBuffer.Open("testFileForClose")
+	err = myBuffer.Close()
 	if err != nil {
 		t.Fatal(err)
 	}
 	_ = os.Remove("./testFileForClose")
 }
 
+/*
+TestBufferManagerCloseFlushes tests that Close writes dirty pages back without an explicit Flush
+*/
+func TestBufferManagerCloseFlushes(t *testing.T) {
+	storage := NewMemoryStorage()
+	myBuffer, _ := CreateNewBufferManager("./", uint64(1024), WithStorage(storage))
+	_, _ = myBuffer.AllocatePage("testFileForCloseFlush")
+	id, err := myBuffer.Pin("testFileForCloseFlush", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	myBuffer.Pages[id].Values[0] = 42
+	_ = myBuffer.Unpin(id, true)
+	err = myBuffer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restarted, _ := CreateNewBufferManager("./", uint64(1024), WithStorage(storage))
+	defer restarted.Close()
+	id, err = restarted.Pin("testFileForCloseFlush", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restarted.Pages[id].Values[0] != 42 {
+		t.Fatal("Close has not written the dirty page back")
+	}
+	_ = restarted.Unpin(id, false)
+}
+
 /*
 TestBufferManagerDelete tests if we receive an expected error when closing a page that is not open
 */
@@ -93,7 +153,7 @@ TestBufferManagerPin tests if the pinning of a page works correctly or not
 func TestBufferManagerPin(t *testing.T) {
 	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024))
 	file, _ := os.Create("./testFileForPin")
-	_, _ = file.Write([]byte("1;2;3;4;5;6;a;b;c;d;e;f;g"))
+	_, _ = file.Write([]byte("1;2;3;4;5;6;7;8;9;10;11;12;13"))
 	_ = file.Close()
 	id, err := myBuffer.Pin("testFileForPin", uint64(0))
 	if err != nil {
@@ -104,10 +164,12 @@ func TestBufferManagerPin(t *testing.T) {
 		t.Fatal("Id is invalid")
 	}
 
-	if myBuffer.PageMap[uint64(0)] != id {
+	if myBuffer.PageMap[PageKey{File: "testFileForPin", Page: 0}] != id {
 		t.Fatal("PageMap has not been updated properly")
 	}
 
+	_ = myBuffer.Unpin(id, false)
+	_ = myBuffer.Close()
 	_ = os.Remove("./testFileForPin")
 }
 
@@ -123,12 +185,12 @@ func TestBufferManagerPinWithError(t *testing.T) {
 }
 
 /*
-TestBufferManagerPin tests if the unpinning of a page works correctly or not
+TestBufferManagerUnpin tests if the unpinning of a page works correctly or not
 */
 func TestBufferManagerUnpin(t *testing.T) {
 	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024))
 	file, _ := os.Create("./testFileForUnPin")
-	_, _ = file.Write([]byte("1;2;3;4;5;6;a;b;c;d;e;f;g"))
+	_, _ = file.Write([]byte("1;2;3;4;5;6;7;8;9;10;11;12;13"))
 	_ = file.Close()
 
 	id, err := myBuffer.Pin("testFileForUnPin", uint64(0))
@@ -137,27 +199,37 @@ func TestBufferManagerUnpin(t *testing.T) {
 		t.Errorf("Error occured when trying to pin: %s", err)
 	}
 
-	if myBuffer.PageMap[uint64(0)] != id {
-		t.Fatalf("PageMap has not been updated properly, expected %v got %v", id, myBuffer.PageMap[uint64(0)])
+	if myBuffer.PageMap[PageKey{File: "testFileForUnPin", Page: 0}] != id {
+		t.Fatalf("PageMap has not been updated properly, expected %v got %v", id, myBuffer.PageMap[PageKey{File: "testFileForUnPin", Page: 0}])
 	}
 	_ = os.Remove("./testFileForUnPin")
 
-	err = myBuffer.Unpin(id)
+	err = myBuffer.Unpin(id, false)
 
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	if len(myBuffer.PageMap) != 0 {
-		t.Fatalf("PageMap has not been updated properly with removed values, lenght is %v", len(myBuffer.PageMap))
+	if myBuffer.frames[id].pinCount != 0 {
+		t.Fatalf("pin count has not been decremented, it is %v", myBuffer.frames[id].pinCount)
 	}
+
+	if _, ok := myBuffer.PageMap[PageKey{File: "testFileForUnPin", Page: 0}]; !ok {
+		t.Fatal("unpinned page should stay in the buffer until it is evicted")
+	}
+
+	err = myBuffer.Unpin(id, false)
+	if err == nil {
+		t.Fatal("unpinning a page that is not pinned should return an error but does not")
+	}
+	_ = myBuffer.Close()
 }
 
 func TestBufferManagerUnpinWithError(t *testing.T) {
 	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024))
 	id, _ := myBuffer.Pin("testFileForUnPinNonExistent", uint64(0))
 
-	err := myBuffer.Unpin(id)
+	err := myBuffer.Unpin(id, false)
 	if err == nil {
 		t.Fatal("this should have returned an error but does not")
 	}
@@ -172,13 +244,16 @@ func TestBufferManagerSerializeRootOnly(t *testing.T) {
 	defer func() {
 		_ = os.Remove("./testFileForSerialize")
 	}()
+	defer func() {
+		_ = myBuffer.Close()
+	}()
 
 	keys := [6]uint64{uint64(11), uint64(12), uint64(13), uint64(14), uint64(15), uint64(16)}
 	values := [7]uint64{uint64(21), uint64(22), uint64(23), uint64(24), uint64(25), uint64(26), uint64(27)}
 	page := Page{pageId: 0, Name: "testFileForSerialize", Keys: keys, Values: values}
 
 	myBuffer.Pages[0] = page
-	myBuffer.PageMap[0] = 0
+	myBuffer.PageMap[page.key()] = 0
 
 	err := myBuffer.serialize(0)
 
@@ -196,6 +271,9 @@ func TestBufferManagerSerializeTwoNodes(t *testing.T) {
 	defer func() {
 		_ = os.Remove("./testFileForSerializeTwoNodes")
 	}()
+	defer func() {
+		_ = myBuffer.Close()
+	}()
 
 	keys := [6]uint64{uint64(11), uint64(12), uint64(13), uint64(14), uint64(15), uint64(16)}
 	values := [7]uint64{uint64(21), uint64(22), uint64(23), uint64(24), uint64(25), uint64(26), uint64(27)}
@@ -204,8 +282,8 @@ func TestBufferManagerSerializeTwoNodes(t *testing.T) {
 
 	myBuffer.Pages[0] = page
 	myBuffer.Pages[1] = page1
-	myBuffer.PageMap[0] = 0
-	myBuffer.PageMap[1] = 1
+	myBuffer.PageMap[page.key()] = 0
+	myBuffer.PageMap[page1.key()] = 1
 
 	err := myBuffer.serialize(0)
 
@@ -218,3 +296,344 @@ func TestBufferManagerSerializeTwoNodes(t *testing.T) {
 		t.Fatalf("error while deserializing page1: %v", err)
 	}
 }
+
+/*
+createFileWithPages writes a file with the given amount of pages, page i has the key i+1 with the value i+100
+*/
+func createFileWithPages(t *testing.T, name string, pages int) {
+	var outputString = ""
+	for i := 0; i < pages; i++ {
+		outputString = outputString + strconv.Itoa(i+1) + ";;;;;;" + strconv.Itoa(i+100) + ";;;;;;\n"
+	}
+	err := os.WriteFile("./"+name, []byte(outputString), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+/*
+TestBufferManagerPinEvicts tests that more pages than frames can be pinned one after another
+*/
+func TestBufferManagerPinEvicts(t *testing.T) {
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024))
+	createFileWithPages(t, "testFileForEviction", 2*len(myBuffer.Pages))
+	defer func() {
+		_ = os.Remove("./testFileForEviction")
+	}()
+	defer func() {
+		_ = myBuffer.Close()
+	}()
+
+	for pageInFile := uint64(0); pageInFile < uint64(2*len(myBuffer.Pages)); pageInFile++ {
+		id, err := myBuffer.Pin("testFileForEviction", pageInFile)
+		if err != nil {
+			t.Fatalf("error while pinning page %d: %v", pageInFile, err)
+		}
+		if myBuffer.Pages[id].Keys[0] != pageInFile+1 {
+			t.Fatalf("frame %d holds key %d instead of %d", id, myBuffer.Pages[id].Keys[0], pageInFile+1)
+		}
+		_ = myBuffer.Unpin(id, false)
+	}
+
+	if _, ok := myBuffer.PageMap[PageKey{File: "testFileForEviction", Page: 0}]; ok {
+		t.Fatal("the least recently used page has not been evicted")
+	}
+}
+
+/*
+TestBufferManagerPinWritesBackDirtyVictim tests that a modified page is written to disk when it is evicted
+*/
+func TestBufferManagerPinWritesBackDirtyVictim(t *testing.T) {
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024))
+	createFileWithPages(t, "testFileForDirtyEviction", len(myBuffer.Pages)+1)
+	defer func() {
+		_ = os.Remove("./testFileForDirtyEviction")
+	}()
+	defer func() {
+		_ = myBuffer.Close()
+	}()
+
+	id, _ := myBuffer.Pin("testFileForDirtyEviction", 0)
+	myBuffer.Pages[id].Values[0] = 42
+	_ = myBuffer.Unpin(id, true)
+
+	for pageInFile := uint64(1); pageInFile <= uint64(len(myBuffer.Pages)); pageInFile++ {
+		_, err := myBuffer.Pin("testFileForDirtyEviction", pageInFile)
+		if err != nil {
+			t.Fatalf("error while pinning page %d: %v", pageInFile, err)
+		}
+	}
+
+	_ = myBuffer.Close()
+	restarted, _ := CreateNewBufferManager("./", uint64(1024))
+	id, err := restarted.Pin("testFileForDirtyEviction", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restarted.Pages[id].Values[0] != 42 {
+		t.Fatalf("evicted page has value %d on disk instead of 42", restarted.Pages[id].Values[0])
+	}
+	_ = restarted.Unpin(id, false)
+	_ = restarted.Close()
+}
+
+/*
+TestBufferManagerPinWithAllFramesPinned tests that Pin only fails when no page can be evicted
+*/
+func TestBufferManagerPinWithAllFramesPinned(t *testing.T) {
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024))
+	createFileWithPages(t, "testFileForFullBuffer", len(myBuffer.Pages)+1)
+	defer func() {
+		_ = os.Remove("./testFileForFullBuffer")
+	}()
+	defer func() {
+		_ = myBuffer.Close()
+	}()
+
+	for pageInFile := uint64(0); pageInFile < uint64(len(myBuffer.Pages)); pageInFile++ {
+		_, err := myBuffer.Pin("testFileForFullBuffer", pageInFile)
+		if err != nil {
+			t.Fatalf("error while pinning page %d: %v", pageInFile, err)
+		}
+	}
+
+	_, err := myBuffer.Pin("testFileForFullBuffer", uint64(len(myBuffer.Pages)))
+	if err != ErrNoFreeFrame {
+		t.Fatalf("Pin should fail with ErrNoFreeFrame when every frame is pinned but returned %v", err)
+	}
+
+	_ = myBuffer.Unpin(myBuffer.PageMap[PageKey{File: "testFileForFullBuffer", Page: 3}], false)
+	_, err = myBuffer.Pin("testFileForFullBuffer", uint64(len(myBuffer.Pages)))
+	if err != nil {
+		t.Fatalf("Pin should evict the unpinned page but returned: %v", err)
+	}
+}
+
+/*
+TestBufferManagerFlushOnlyDirty tests that Flush only writes pages that have been unpinned as dirty
+*/
+func TestBufferManagerFlushOnlyDirty(t *testing.T) {
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024))
+	createFileWithPages(t, "testFileForFlush", 2)
+	defer func() {
+		_ = os.Remove("./testFileForFlush")
+	}()
+	defer func() {
+		_ = myBuffer.Close()
+	}()
+
+	cleanID, _ := myBuffer.Pin("testFileForFlush", 0)
+	dirtyID, _ := myBuffer.Pin("testFileForFlush", 1)
+	myBuffer.Pages[cleanID].Values[0] = 42
+	myBuffer.Pages[dirtyID].Values[0] = 43
+	_ = myBuffer.Unpin(cleanID, false)
+	_ = myBuffer.Unpin(dirtyID, true)
+
+	if !myBuffer.frames[dirtyID].dirty || myBuffer.frames[cleanID].dirty {
+		t.Fatal("Unpin has not set the dirty flags properly")
+	}
+
+	err := myBuffer.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if myBuffer.frames[dirtyID].dirty {
+		t.Fatal("page is still dirty after Flush")
+	}
+
+	_ = myBuffer.Close()
+	restarted, _ := CreateNewBufferManager("./", uint64(1024))
+	id, _ := restarted.Pin("testFileForFlush", 0)
+	if restarted.Pages[id].Values[0] != 100 {
+		t.Fatalf("clean page has been written, value on disk is %d instead of 100", restarted.Pages[id].Values[0])
+	}
+	id, _ = restarted.Pin("testFileForFlush", 1)
+	if restarted.Pages[id].Values[0] != 43 {
+		t.Fatalf("dirty page has not been written, value on disk is %d instead of 43", restarted.Pages[id].Values[0])
+	}
+	_ = restarted.Close()
+}
+
+/*
+TestBufferManagerMemoryBudget tests that the number of frames follows the memory budget
+*/
+func TestBufferManagerMemoryBudget(t *testing.T) {
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024))
+	if len(myBuffer.Pages) != 1024/PageSize {
+		t.Fatalf("buffer has %d frames instead of %d", len(myBuffer.Pages), 1024/PageSize)
+	}
+
+	_, err := CreateNewBufferManager("./", uint64(PageSize-1))
+	if err == nil {
+		t.Fatal("a memory budget smaller than one page should return an error but does not")
+	}
+}
+
+/*
+TestBufferManagerResize tests that shrinking evicts the pages of the removed frames and growing adds frames
+*/
+func TestBufferManagerResize(t *testing.T) {
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(4*PageSize))
+	createFileWithPages(t, "testFileForResize", 4)
+	defer func() {
+		_ = os.Remove("./testFileForResize")
+	}()
+	defer func() {
+		_ = myBuffer.Close()
+	}()
+
+	for pageInFile := uint64(0); pageInFile < 4; pageInFile++ {
+		id, _ := myBuffer.Pin("testFileForResize", pageInFile)
+		myBuffer.Pages[id].Values[0] = 42
+		_ = myBuffer.Unpin(id, pageInFile == 3)
+	}
+	pinnedID, _ := myBuffer.Pin("testFileForResize", 3)
+
+	err := myBuffer.Resize(uint64(2 * PageSize))
+	if err == nil {
+		t.Fatal("shrinking over a pinned page should return an error but does not")
+	}
+	if len(myBuffer.Pages) != 4 {
+		t.Fatal("failed Resize has changed the buffer")
+	}
+
+	_ = myBuffer.Unpin(pinnedID, false)
+	err = myBuffer.Resize(uint64(2 * PageSize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(myBuffer.Pages) != 2 || len(myBuffer.PageMap) != 2 {
+		t.Fatalf("buffer has %d frames and %d pages after shrinking instead of 2", len(myBuffer.Pages), len(myBuffer.PageMap))
+	}
+
+	_ = myBuffer.Close()
+	restarted, _ := CreateNewBufferManager("./", uint64(1024))
+	id, _ := restarted.Pin("testFileForResize", 3)
+	if restarted.Pages[id].Values[0] != 42 {
+		t.Fatal("dirty page has not been written back when its frame was removed")
+	}
+	_ = restarted.Close()
+
+	err = myBuffer.Resize(uint64(8 * PageSize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for pageInFile := uint64(0); pageInFile < 4; pageInFile++ {
+		_, err = myBuffer.Pin("testFileForResize", pageInFile)
+		if err != nil {
+			t.Fatalf("error while pinning page %d after growing: %v", pageInFile, err)
+		}
+	}
+}
+
+/*
+TestBufferManagerPerFile tests that pages of different files with the same number are kept apart
+and that flushing and deleting only affect one file
+*/
+func TestBufferManagerPerFile(t *testing.T) {
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024))
+	createFileWithPages(t, "testFileForPerFileA", 1)
+	_ = os.WriteFile("./testFileForPerFileB", []byte("7;;;;;;8;;;;;;"), 0644)
+	defer func() {
+		_ = os.Remove("./testFileForPerFileA")
+		_ = os.Remove("./testFileForPerFileB")
+	}()
+	defer func() {
+		_ = myBuffer.Close()
+	}()
+
+	idA, _ := myBuffer.Pin("testFileForPerFileA", 0)
+	idB, _ := myBuffer.Pin("testFileForPerFileB", 0)
+	if idA == idB || myBuffer.Pages[idB].Keys[0] != 7 {
+		t.Fatal("page 0 of the second file has not been loaded into its own frame")
+	}
+
+	myBuffer.Pages[idA].Values[0] = 42
+	myBuffer.Pages[idB].Values[0] = 43
+	_ = myBuffer.UnpinPage("testFileForPerFileA", 0, true)
+	_ = myBuffer.UnpinPage("testFileForPerFileB", 0, true)
+
+	err := myBuffer.FlushFile("testFileForPerFileA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if myBuffer.frames[idA].dirty || !myBuffer.frames[idB].dirty {
+		t.Fatal("FlushFile has not only written the pages of its file")
+	}
+
+	err = myBuffer.Delete("testFileForPerFileB")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := myBuffer.PageMap[PageKey{File: "testFileForPerFileB", Page: 0}]; ok {
+		t.Fatal("pages of a deleted file are still in the buffer")
+	}
+	if _, ok := myBuffer.PageMap[PageKey{File: "testFileForPerFileA", Page: 0}]; !ok {
+		t.Fatal("deleting a file has removed the pages of another file")
+	}
+	err = myBuffer.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat("./testFileForPerFileB"); !os.IsNotExist(err) {
+		t.Fatal("dirty page of a deleted file has been written back")
+	}
+}
+
+/*
+TestBufferManagerPinContextWaits tests that PinContext waits until a frame is unpinned and reports how long it waited
+*/
+func TestBufferManagerPinContextWaits(t *testing.T) {
+	storage := NewMemoryStorage()
+	createTreeInStorage(t, storage, "testFileForPinContext")
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(PageSize), WithStorage(storage))
+
+	pageID, _ := myBuffer.Pin("testFileForPinContext", 0)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		_ = myBuffer.Unpin(pageID, false)
+	}()
+	otherID, waited, err := myBuffer.PinContext(context.Background(), "testFileForPinContext", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if waited < 10*time.Millisecond {
+		t.Fatalf("PinContext reports %v although it had to wait for the unpin", waited)
+	}
+	page, _ := myBuffer.Page(otherID)
+	if page.Keys[0] != 1 {
+		t.Fatalf("PinContext pinned a page with key %d instead of 1", page.Keys[0])
+	}
+
+	_, waited, err = myBuffer.PinContext(context.Background(), "testFileForPinContext", 1)
+	if err != nil || waited != 0 {
+		t.Fatalf("PinContext of a page in the buffer returned %v after waiting %v", err, waited)
+	}
+}
+
+/*
+TestBufferManagerPinContextWithError tests that PinContext gives up at the deadline or when it is canceled
+*/
+func TestBufferManagerPinContextWithError(t *testing.T) {
+	storage := NewMemoryStorage()
+	createTreeInStorage(t, storage, "testFileForPinContext")
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(PageSize), WithStorage(storage))
+	_, _ = myBuffer.Pin("testFileForPinContext", 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, waited, err := myBuffer.PinContext(ctx, "testFileForPinContext", 1)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("PinContext returned %v instead of context.DeadlineExceeded", err)
+	}
+	if waited < 10*time.Millisecond {
+		t.Fatalf("PinContext reports %v although it waited until the deadline", waited)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	_, waited, err = myBuffer.PinContext(ctx, "testFileForPinContext", 0)
+	if !errors.Is(err, context.Canceled) || waited != 0 {
+		t.Fatalf("PinContext with a canceled context returned %v after waiting %v", err, waited)
+	}
+}
@@ -0,0 +1,72 @@
+package src
+
+/*
+clockReplacer sweeps a hand over the frames and evicts the first evictable frame whose reference bit is not set.
+Frames the hand passes lose their reference bit, so a page survives one sweep after its last pin.
+*/
+type clockReplacer struct {
+	ring    []*clockEntry
+	entries map[uint64]*clockEntry
+	hand    int
+}
+
+type clockEntry struct {
+	frameID    uint64
+	referenced bool
+	evictable  bool
+}
+
+func newClockReplacer() *clockReplacer {
+	return &clockReplacer{entries: make(map[uint64]*clockEntry)}
+}
+
+func (r *clockReplacer) RecordAccess(frameID uint64, page PageKey) {
+	if entry, ok := r.entries[frameID]; ok {
+		entry.referenced = true
+		return
+	}
+	entry := &clockEntry{frameID: frameID, referenced: true}
+	r.entries[frameID] = entry
+	r.ring = append(r.ring, entry)
+}
+
+func (r *clockReplacer) SetEvictable(frameID uint64, evictable bool) {
+	if entry, ok := r.entries[frameID]; ok {
+		entry.evictable = evictable
+	}
+}
+
+func (r *clockReplacer) Evict() (uint64, bool) {
+	// after one full sweep every evictable frame has lost its reference bit
+	for i := 0; i < 2*len(r.ring); i++ {
+		if r.hand >= len(r.ring) {
+			r.hand = 0
+		}
+		entry := r.ring[r.hand]
+		if entry.evictable && !entry.referenced {
+			r.Remove(entry.frameID)
+			return entry.frameID, true
+		}
+		if entry.evictable {
+			entry.referenced = false
+		}
+		r.hand++
+	}
+	return 0, false
+}
+
+func (r *clockReplacer) Remove(frameID uint64) {
+	if _, ok := r.entries[frameID]; !ok {
+		return
+	}
+	delete(r.entries, frameID)
+	for i, entry := range r.ring {
+		if entry.frameID == frameID {
+			r.ring = append(r.ring[:i], r.ring[i+1:]...)
+			if i < r.hand {
+				r.hand--
+			}
+			return
+		}
+	}
+}
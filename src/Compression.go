@@ -0,0 +1,75 @@
+package src
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+/*
+WithCompression creates new binary tree files with compressed pages which take less space on disk but more time
+to read and write. Existing files keep the format they have been created in.
+*/
+func WithCompression(compress bool) Option {
+	return func(bm *BufferManager) {
+		bm.compress = compress
+	}
+}
+
+/*
+compressors and decompressors keep the state of flate between pages, setting it up takes longer than compressing a page
+*/
+var (
+	compressors = sync.Pool{New: func() interface{} {
+		// the level is valid, so there is no error
+		writer, _ := flate.NewWriter(nil, flate.BestCompression)
+		return writer
+	}}
+	decompressors sync.Pool
+)
+
+/*
+compressPage compresses an encoded page
+*/
+func compressPage(buf []byte) ([]byte, error) {
+	var out bytes.Buffer
+	writer := compressors.Get().(*flate.Writer)
+	defer compressors.Put(writer)
+	writer.Reset(&out)
+	_, err := writer.Write(buf)
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+/*
+decompressPage restores an encoded page, more than a page of content is rejected
+*/
+func decompressPage(data []byte) ([]byte, error) {
+	reader, ok := decompressors.Get().(io.ReadCloser)
+	if ok {
+		err := reader.(flate.Resetter).Reset(bytes.NewReader(data), nil)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		reader = flate.NewReader(bytes.NewReader(data))
+	}
+	defer decompressors.Put(reader)
+	buf := make([]byte, PageSize+1)
+	n, err := io.ReadFull(reader, buf)
+	if err == nil {
+		return nil, errors.New("the slot holds more than one page")
+	}
+	if err != io.ErrUnexpectedEOF {
+		return nil, fmt.Errorf("the slot cannot be decompressed: %v", err)
+	}
+	return buf[:n], nil
+}
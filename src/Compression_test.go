@@ -0,0 +1,243 @@
+package src
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+/*
+TestCompressionConvertAndUse tests that a compressed file can be used like an uncompressed one and converted back
+*/
+func TestCompressionConvertAndUse(t *testing.T) {
+	storage := NewMemoryStorage()
+	createTreeInStorage(t, storage, "testFileForCompression")
+	myConverter := Converter{Storage: storage}
+	err := myConverter.Compress("testFileForCompression", "testFileForCompression")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dat, _ := readStorageFile(storage, "testFileForCompression")
+	if len(dat) >= 4*PageSize {
+		t.Errorf("compressed file has %d bytes, the uncompressed one has %d", len(dat), 4*PageSize)
+	}
+
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024), WithStorage(storage))
+	header, err := myBuffer.Header("testFileForCompression")
+	if err != nil || !header.Compressed || header.Height != 1 {
+		t.Fatalf("Header returned %+v, %v", header, err)
+	}
+	var myLoader = Loader{}
+	tree, err := myLoader.Load("testFileForCompression", myBuffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = tree.Push(3, 4)
+	err = myBuffer.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = myBuffer.Close()
+	var otherBuffer, _ = CreateNewBufferManager("./", uint64(1024), WithStorage(storage))
+	tree, err = myLoader.Load("testFileForCompression", otherBuffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, expected := range map[uint64]uint64{1: 2, 3: 4, 11: 12} {
+		value, err := tree.Get(key)
+		if err != nil || value != expected {
+			t.Errorf("Get(%d) returned %d, %v instead of %d", key, value, err, expected)
+		}
+	}
+	_ = otherBuffer.Close()
+
+	if err := myConverter.BinaryToText("testFileForCompression", "testFileForCompressionText"); err == nil {
+		t.Error("a compressed file has been converted to text")
+	}
+	err = myConverter.Decompress("testFileForCompression", "testFileForCompression")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = myConverter.BinaryToText("testFileForCompression", "testFileForCompressionText")
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, _ := readStorageFile(storage, "testFileForCompressionText")
+	if string(text) != "10;;;;;;1;2;;;;;\n1;3;;;;;2;4;;;;;\n11;;;;;;12;;;;;;" {
+		t.Errorf("decompressed file has been converted to %q", text)
+	}
+}
+
+/*
+TestCompressionReusesSpace tests that new files are created compressed and do not grow when pages are rewritten
+*/
+func TestCompressionReusesSpace(t *testing.T) {
+	storage := NewMemoryStorage()
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024), WithStorage(storage), WithCompression(true))
+	for i := 0; i < 3; i++ {
+		_, err := myBuffer.AllocatePage("testFileForSlots")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := uint64(0); i < 50; i++ {
+		pageID, err := myBuffer.Pin("testFileForSlots", i%3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		myBuffer.Pages[pageID].Keys[0] = i
+		_ = myBuffer.Unpin(pageID, true)
+		err = myBuffer.Flush()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	dat, _ := readStorageFile(storage, "testFileForSlots")
+	if len(dat) >= 2*PageSize {
+		t.Errorf("file has grown to %d bytes for 3 pages", len(dat))
+	}
+
+	_ = myBuffer.Close()
+	var otherBuffer, _ = CreateNewBufferManager("./", uint64(1024), WithStorage(storage))
+	header, _ := otherBuffer.Header("testFileForSlots")
+	if !header.Compressed {
+		t.Error("the new file has not been created compressed")
+	}
+	for pageInFile, expected := range []uint64{48, 49, 47} {
+		pageID, err := otherBuffer.Pin("testFileForSlots", uint64(pageInFile))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if otherBuffer.Pages[pageID].Keys[0] != expected {
+			t.Errorf("page %d has key %d instead of %d", pageInFile, otherBuffer.Pages[pageID].Keys[0], expected)
+		}
+		_ = otherBuffer.Unpin(pageID, false)
+	}
+}
+
+/*
+TestCompressionKeepsSlotIndex tests that the free space and the table that are kept between writes match the ones worked out from scratch
+*/
+func TestCompressionKeepsSlotIndex(t *testing.T) {
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024), WithStorage(NewMemoryStorage()), WithCompression(true))
+	defer myBuffer.Close()
+	for i := 0; i < 8; i++ {
+		_, _ = myBuffer.AllocatePage("testFileForSlotIndex")
+	}
+	for i := uint64(0); i < 60; i++ {
+		pageID, err := myBuffer.Pin("testFileForSlotIndex", i*5%8)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// pages with more distinct keys compress worse, so the slots change their size
+		for k := uint64(0); k < i%6; k++ {
+			myBuffer.Pages[pageID].Keys[k] = i*1000003 + k*7919
+		}
+		_ = myBuffer.Unpin(pageID, true)
+		err = myBuffer.Flush()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		slots := myBuffer.files["testFileForSlotIndex"].pageFile.(*binaryPageFile).slots
+		if expected := slots.freeSpace(); !reflect.DeepEqual(slots.space, expected) {
+			t.Fatalf("free space is %v instead of %v after write %d", *slots.space, *expected, i)
+		}
+		if !bytes.Equal(slots.encoded, encodeSlotTable(slots.slots)) {
+			t.Fatalf("the kept table does not match the slots after write %d", i)
+		}
+	}
+}
+
+/*
+TestCompressionCorruptSlot tests that a damaged slot is reported as corruption of its page only
+*/
+func TestCompressionCorruptSlot(t *testing.T) {
+	storage := NewFaultStorage(NewMemoryStorage())
+	createTreeInStorage(t, storage, "testFileForCorruptSlot")
+	myConverter := Converter{Storage: storage}
+	_ = myConverter.Compress("testFileForCorruptSlot", "testFileForCorruptSlot")
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024), WithStorage(storage))
+	file, err := myBuffer.openPageFile("testFileForCorruptSlot", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	place := file.(*binaryPageFile).slots.slots[2]
+	_ = file.Close()
+
+	storage.FlipBit("testFileForCorruptSlot", place.offset+place.size/2, 0)
+	_, err = myBuffer.Pin("testFileForCorruptSlot", 2)
+	var corruptionError *CorruptionError
+	if !errors.As(err, &corruptionError) || corruptionError.Page != 2 {
+		t.Fatalf("expected a CorruptionError for page 2 but got %v", err)
+	}
+	_, err = myBuffer.Pin("testFileForCorruptSlot", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+/*
+TestCompressionCrash tests that a crash after any write leaves a compressed file with either all or none of a flush
+*/
+func TestCompressionCrash(t *testing.T) {
+	create := func(storage Storage) {
+		createTreeInStorage(t, storage, "testFileForCompressedCrash")
+		myConverter := Converter{Storage: storage}
+		_ = myConverter.Compress("testFileForCompressedCrash", "testFileForCompressedCrash")
+	}
+	run := func(storage Storage) {
+		var myBuffer, _ = CreateNewBufferManager("./", uint64(1024), WithStorage(storage))
+		defer func() {
+			_ = myBuffer.Close()
+		}()
+		var myLoader = Loader{}
+		tree, err := myLoader.Load("testFileForCompressedCrash", myBuffer)
+		if err != nil {
+			return
+		}
+		_ = tree.Push(12, 13)
+		_ = tree.Push(3, 4)
+		_ = myBuffer.Flush()
+		pageInFile, _ := myBuffer.AllocatePage("testFileForCompressedCrash")
+		_ = myBuffer.FreePage("testFileForCompressedCrash", pageInFile)
+	}
+
+	inner := NewMemoryStorage()
+	create(inner)
+	storage := NewFaultStorage(inner)
+	run(storage)
+	writes := storage.Writes()
+
+	for crashAfter := 0; crashAfter <= writes; crashAfter++ {
+		inner := NewMemoryStorage()
+		create(inner)
+		storage := NewFaultStorage(inner)
+		storage.CrashAfter(crashAfter)
+		run(storage)
+
+		var myBuffer, _ = CreateNewBufferManager("./", uint64(1024), WithStorage(inner))
+		var myLoader = Loader{}
+		tree, err := myLoader.Load("testFileForCompressedCrash", myBuffer)
+		if err != nil {
+			t.Fatalf("crash after %d writes: %v", crashAfter, err)
+		}
+		for key, expected := range map[uint64]uint64{1: 2, 11: 12} {
+			value, err := tree.Get(key)
+			if err != nil || value != expected {
+				t.Fatalf("crash after %d writes: Get(%d) returned %d, %v instead of %d", crashAfter, key, value, err, expected)
+			}
+		}
+		first, _ := tree.Get(12)
+		second, _ := tree.Get(3)
+		if (first == 13) != (second == 4) {
+			t.Fatalf("crash after %d writes: only part of the flush is on disk", crashAfter)
+		}
+		err = myBuffer.CheckFreeList("testFileForCompressedCrash")
+		if err != nil {
+			t.Fatalf("crash after %d writes: %v", crashAfter, err)
+		}
+	}
+}
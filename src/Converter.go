@@ -0,0 +1,320 @@
+package src
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+/*
+Converter translates tree files between the semicolon separated text format and the binary format.
+Source and destination may be the same file to migrate it in place. Like a BufferManager it locks the files
+while it converts them, so it fails with ErrLocked for files that a BufferManager is using.
+*/
+type Converter struct {
+	Storage Storage // holds the files to convert, the file system relative to the working directory if nil
+}
+
+/*
+ConversionError reports where the input of a conversion is malformed.
+Line and Field start at 1, Field is 0 if the line as a whole is wrong.
+*/
+type ConversionError struct {
+	File   string
+	Line   int
+	Field  int
+	Reason string
+}
+
+func (e *ConversionError) Error() string {
+	if e.Field == 0 {
+		return fmt.Sprintf("%s line %d: %s", e.File, e.Line, e.Reason)
+	}
+	return fmt.Sprintf("%s line %d field %d: %s", e.File, e.Line, e.Field, e.Reason)
+}
+
+/*
+storage returns the Storage the files are converted in
+*/
+func (c *Converter) storage() Storage {
+	if c.Storage == nil {
+		return NewDiskStorage("")
+	}
+	return c.Storage
+}
+
+/*
+lock takes a shared lock on src and an exclusive one on dst, the returned function releases them
+*/
+func (c *Converter) lock(src string, dst string) (func(), error) {
+	locker, ok := c.storage().(Locker)
+	if !ok {
+		return func() {}, nil
+	}
+	var locks []io.Closer
+	release := func() {
+		for _, lock := range locks {
+			_ = lock.Close()
+		}
+	}
+	if src != dst {
+		lock, err := locker.Lock(lockName(src), false)
+		if errors.Is(err, ErrLocked) {
+			return nil, fmt.Errorf("%s cannot be read, it is opened for writing elsewhere: %w", src, err)
+		}
+		if err != nil {
+			return nil, err
+		}
+		locks = append(locks, lock)
+	}
+	lock, err := locker.Lock(lockName(dst), true)
+	if errors.Is(err, ErrLocked) {
+		err = fmt.Errorf("%s cannot be written, it is opened elsewhere: %w", dst, err)
+	}
+	if err != nil {
+		release()
+		return nil, err
+	}
+	locks = append(locks, lock)
+	return release, nil
+}
+
+/*
+TextToBinary converts the text file src into the binary file dst.
+Every row is validated first, nothing is written if one of them is malformed.
+The free list of src becomes the free list inside dst.
+*/
+func (c *Converter) TextToBinary(src string, dst string) error {
+	release, err := c.lock(src, dst)
+	if err != nil {
+		return err
+	}
+	defer release()
+	dat, err := readStorageFile(c.storage(), src)
+	if err != nil {
+		return err
+	}
+	freeList, err := readFreeListFile(c.storage(), src)
+	if err != nil {
+		return err
+	}
+
+	var rows []string
+	if len(dat) > 0 {
+		rows = strings.Split(strings.TrimSuffix(string(dat), "\n"), "\n")
+	}
+	output := make([]byte, (len(rows)+1)*PageSize)
+	for i, row := range rows {
+		page, field, err := parseTextRow(row)
+		if err != nil {
+			return &ConversionError{File: src, Line: i + 1, Field: field, Reason: err.Error()}
+		}
+		encodePage(page, output[(i+1)*PageSize:(i+2)*PageSize])
+	}
+
+	// legacy files have their root in page 0 with one level of leaves below it
+	header := newHeader()
+	header.Height = 1
+	for i := len(freeList) - 1; i >= 0; i-- {
+		if freeList[i] >= uint64(len(rows)) {
+			return &ConversionError{File: freeListName(src), Line: len(freeList) - i, Reason: fmt.Sprintf("page %d is not part of the file", freeList[i])}
+		}
+		buf := output[(freeList[i]+1)*PageSize : (freeList[i]+2)*PageSize]
+		for j := range buf {
+			buf[j] = 0
+		}
+		binary.LittleEndian.PutUint64(buf[freeNextOffset:], header.FreeHead)
+		buf[freeMarkOffset] = 1
+		setChecksum(buf)
+		header.FreeHead = freeList[i]
+	}
+	encodeHeader(header, output[:PageSize])
+
+	err = writeFileAtomic(c.storage(), dst, output, true)
+	if err != nil {
+		return err
+	}
+	// the free list is part of the binary file now
+	return removeIfExists(c.storage(), freeListName(dst))
+}
+
+/*
+BinaryToText converts the binary file src into the text file dst.
+A page whose checksum does not match is reported as CorruptionError. Only trees with their root in page 0
+and one level of leaves below it can be written in the text format.
+*/
+func (c *Converter) BinaryToText(src string, dst string) error {
+	release, err := c.lock(src, dst)
+	if err != nil {
+		return err
+	}
+	defer release()
+	dat, err := readStorageFile(c.storage(), src)
+	if err != nil {
+		return err
+	}
+	if header, err := decodeHeader(src, dat); err == nil && (header.Compressed || header.Encrypted) {
+		return fmt.Errorf("%s has compressed or encrypted pages, only uncompressed pages can be converted", src)
+	}
+	if len(dat) == 0 || len(dat)%PageSize != 0 {
+		return &ConversionError{File: src, Line: len(dat)/PageSize + 1, Reason: fmt.Sprintf("file size %d is not a multiple of the page size %d", len(dat), PageSize)}
+	}
+	header, err := decodeHeader(src, dat[:PageSize])
+	if err != nil {
+		return err
+	}
+	if header.Root != 0 || header.Height != 1 {
+		return fmt.Errorf("%s has its root in page %d with height %d, the text format only supports root page 0 with height 1", src, header.Root, header.Height)
+	}
+
+	pages := dat[PageSize:]
+	rows := make([]string, len(pages)/PageSize)
+	for i := range rows {
+		buf := pages[i*PageSize : (i+1)*PageSize]
+		if !validChecksum(buf) {
+			return &CorruptionError{File: src, Page: uint64(i), Reason: "checksum mismatch"}
+		}
+		if buf[freeMarkOffset] == 1 {
+			// free pages are empty lines in the text format
+			rows[i] = encodeRow(Page{})
+			continue
+		}
+		rows[i] = encodeRow(decodePage(buf))
+	}
+
+	var freeList []uint64
+	seen := make(map[uint64]bool)
+	for pageInFile := header.FreeHead; pageInFile != noPage; {
+		if pageInFile >= uint64(len(rows)) || seen[pageInFile] {
+			return &CorruptionError{File: src, Page: pageInFile, Reason: "the free list is broken"}
+		}
+		buf := pages[pageInFile*PageSize : (pageInFile+1)*PageSize]
+		if buf[freeMarkOffset] != 1 {
+			return &CorruptionError{File: src, Page: pageInFile, Reason: "page is on the free list but not marked as free"}
+		}
+		seen[pageInFile] = true
+		freeList = append(freeList, pageInFile)
+		pageInFile = binary.LittleEndian.Uint64(buf[freeNextOffset:])
+	}
+
+	err = writeFileAtomic(c.storage(), dst, []byte(strings.Join(rows, "\n")), true)
+	if err != nil {
+		return err
+	}
+	if len(freeList) == 0 {
+		return removeIfExists(c.storage(), freeListName(dst))
+	}
+	return writeFileAtomic(c.storage(), freeListName(dst), []byte(formatFreeList(freeList)), true)
+}
+
+/*
+Compress converts the binary file src into the binary file dst with compressed pages, see WithCompression.
+A page whose checksum does not match is reported as CorruptionError.
+*/
+func (c *Converter) Compress(src string, dst string) error {
+	release, err := c.lock(src, dst)
+	if err != nil {
+		return err
+	}
+	defer release()
+	dat, err := readStorageFile(c.storage(), src)
+	if err != nil {
+		return err
+	}
+	if len(dat) == 0 || len(dat)%PageSize != 0 {
+		return &ConversionError{File: src, Line: len(dat)/PageSize + 1, Reason: fmt.Sprintf("file size %d is not a multiple of the page size %d", len(dat), PageSize)}
+	}
+	header, err := decodeHeader(src, dat[:PageSize])
+	if err != nil {
+		return err
+	}
+	if header.Compressed || header.Encrypted {
+		return fmt.Errorf("%s already has compressed or encrypted pages", src)
+	}
+
+	// the slots are packed behind the header in the order of the pages, the table follows them
+	output := make([]byte, PageSize)
+	slots := make([]slot, len(dat)/PageSize-1)
+	for i := range slots {
+		buf := dat[(i+1)*PageSize : (i+2)*PageSize]
+		if !validChecksum(buf) {
+			return &CorruptionError{File: src, Page: uint64(i), Reason: "checksum mismatch"}
+		}
+		data, err := compressPage(buf)
+		if err != nil {
+			return err
+		}
+		slots[i] = slot{offset: int64(len(output)), size: int64(len(data))}
+		output = append(output, data...)
+	}
+	table := encodeSlotTable(slots)
+	tablePlace := slot{offset: int64(len(output)), size: int64(len(table))}
+	output = append(output, table...)
+	header.Compressed = true
+	encodeHeader(header, output[:PageSize])
+	setTableLocation(output[:PageSize], tablePlace)
+	return writeFileAtomic(c.storage(), dst, output, true)
+}
+
+/*
+Decompress converts the binary file src with compressed pages into the binary file dst with uncompressed pages.
+A page that cannot be restored is reported as CorruptionError.
+*/
+func (c *Converter) Decompress(src string, dst string) error {
+	release, err := c.lock(src, dst)
+	if err != nil {
+		return err
+	}
+	defer release()
+	dat, err := readStorageFile(c.storage(), src)
+	if err != nil {
+		return err
+	}
+	if len(dat) < PageSize {
+		return &ConversionError{File: src, Line: 1, Reason: fmt.Sprintf("file size %d is smaller than the header", len(dat))}
+	}
+	header, err := decodeHeader(src, dat[:PageSize])
+	if err != nil {
+		return err
+	}
+	if !header.Compressed || header.Encrypted {
+		return fmt.Errorf("%s does not have compressed pages without encryption", src)
+	}
+	table, err := loadSlotTable(src, bytes.NewReader(dat), dat[:PageSize])
+	if err != nil {
+		return err
+	}
+
+	output := make([]byte, (len(table.slots)+1)*PageSize)
+	for i, place := range table.slots {
+		if place.end() > int64(len(dat)) {
+			return &CorruptionError{File: src, Page: uint64(i), Reason: "the slot is truncated"}
+		}
+		buf, err := decompressPage(dat[place.offset:place.end()])
+		if err != nil {
+			return &CorruptionError{File: src, Page: uint64(i), Reason: err.Error()}
+		}
+		copy(output[(i+1)*PageSize:], buf)
+	}
+	header.Compressed = false
+	encodeHeader(header, output[:PageSize])
+	return writeFileAtomic(c.storage(), dst, output, true)
+}
+
+/*
+readFreeListFile reads the free list file that belongs to the text file fileID
+*/
+func readFreeListFile(storage Storage, fileID string) ([]uint64, error) {
+	dat, err := readStorageFile(storage, freeListName(fileID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseFreeList(fileID, string(dat))
+}
@@ -0,0 +1,122 @@
+package src
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+/*
+TestConverterRoundTrip tests that a tree migrated in place to the binary format can be read and converted back unchanged
+*/
+func TestConverterRoundTrip(t *testing.T) {
+	original, _ := os.ReadFile("./testFiles/tree2")
+	_ = os.WriteFile("./testFileForConverter", original, 0644)
+	defer func() {
+		_ = os.Remove("./testFileForConverter")
+		_ = os.Remove("./testFileForConverterDump")
+	}()
+	converter := Converter{}
+
+	err := converter.TextToBinary("./testFileForConverter", "./testFileForConverter")
+	if err != nil {
+		t.Fatalf("error while converting to binary: %v", err)
+	}
+
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024))
+	defer func() {
+		_ = myBuffer.Close()
+	}()
+	file, _ := myBuffer.openPageFile("testFileForConverter", false)
+	if _, ok := file.(*binaryPageFile); !ok {
+		t.Fatal("converted file is not in the binary format")
+	}
+	_ = file.Close()
+	tree, err := (&Loader{}).Load("testFileForConverter", myBuffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := tree.Get(24)
+	if err != nil || result != 25 {
+		t.Fatalf("Get(24) returned %d, %v instead of 25 on the converted tree", result, err)
+	}
+	_ = myBuffer.Close()
+
+	err = converter.BinaryToText("./testFileForConverter", "./testFileForConverterDump")
+	if err != nil {
+		t.Fatalf("error while converting to text: %v", err)
+	}
+	dump, _ := os.ReadFile("./testFileForConverterDump")
+	if string(dump) != string(original) {
+		t.Fatalf("text dump %q does not match the original %q", dump, original)
+	}
+}
+
+/*
+TestConverterReportsPosition tests that malformed input is reported with its line and field
+*/
+func TestConverterReportsPosition(t *testing.T) {
+	_ = os.WriteFile("./testFileForConverterError", []byte("10;;;;;;1;2;;;;;\n1;;;;;;x;;;;;;\n11;;;;;;12;;;;;"), 0644)
+	defer func() {
+		_ = os.Remove("./testFileForConverterError")
+	}()
+	converter := Converter{}
+
+	err := converter.TextToBinary("./testFileForConverterError", "./testFileForConverterError")
+	var conversionError *ConversionError
+	if !errors.As(err, &conversionError) {
+		t.Fatalf("expected a ConversionError but got %v", err)
+	}
+	if conversionError.Line != 2 || conversionError.Field != 7 {
+		t.Fatalf("error reported line %d field %d instead of line 2 field 7", conversionError.Line, conversionError.Field)
+	}
+
+	_ = os.WriteFile("./testFileForConverterError", []byte("10;;;;;;1;2;;;;;\n1;;;;;;2;;;;;;\n11;;;;;;12;;;;;"), 0644)
+	err = converter.TextToBinary("./testFileForConverterError", "./testFileForConverterError")
+	if !errors.As(err, &conversionError) || conversionError.Line != 3 || conversionError.Field != 0 {
+		t.Fatalf("a row with too few fields has been reported as %v", err)
+	}
+
+	dat, _ := os.ReadFile("./testFileForConverterError")
+	if string(dat) != "10;;;;;;1;2;;;;;\n1;;;;;;2;;;;;;\n11;;;;;;12;;;;;" {
+		t.Fatal("failed conversion has modified the file")
+	}
+}
+
+/*
+TestConverterLocks tests that files in use by a BufferManager are neither converted nor read by a Converter
+*/
+func TestConverterLocks(t *testing.T) {
+	storage := NewMemoryStorage()
+	createTreeInStorage(t, storage, "testFileForConverterLock")
+	myConverter := Converter{Storage: storage}
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024), WithStorage(storage))
+	pageID, err := myBuffer.Pin("testFileForConverterLock", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = myBuffer.Unpin(pageID, false)
+
+	if err := myConverter.Compress("testFileForConverterLock", "testFileForConverterLock"); !errors.Is(err, ErrLocked) {
+		t.Errorf("converting a file in use returned %v instead of ErrLocked", err)
+	}
+	if err := myConverter.BinaryToText("testFileForConverterLock", "testFileForConverterLockDump"); !errors.Is(err, ErrLocked) {
+		t.Errorf("reading a file that is written elsewhere returned %v instead of ErrLocked", err)
+	}
+	_ = myBuffer.Close()
+
+	err = myConverter.BinaryToText("testFileForConverterLock", "testFileForConverterLockDump")
+	if err != nil {
+		t.Fatalf("the file could not be converted after the BufferManager has been closed: %v", err)
+	}
+	readOnlyBuffer, _ := CreateNewBufferManager("./", uint64(1024), WithStorage(storage), WithReadOnly(true))
+	defer func() {
+		_ = readOnlyBuffer.Close()
+	}()
+	pageID, _ = readOnlyBuffer.Pin("testFileForConverterLock", 0)
+	_ = readOnlyBuffer.Unpin(pageID, false)
+	err = myConverter.BinaryToText("testFileForConverterLock", "testFileForConverterLockDump")
+	if err != nil {
+		t.Fatalf("a file that is only read elsewhere could not be converted: %v", err)
+	}
+}
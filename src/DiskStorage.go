@@ -0,0 +1,79 @@
+package src
+
+import (
+	"os"
+	"path/filepath"
+)
+
+/*
+DiskStorage keeps the files in a directory of the file system
+*/
+type DiskStorage struct {
+	dir string
+}
+
+/*
+NewDiskStorage creates a Storage for the files in dir, the names are appended to dir as they are
+*/
+func NewDiskStorage(dir string) *DiskStorage {
+	return &DiskStorage{dir: dir}
+}
+
+func (s *DiskStorage) Open(name string, create bool) (StorageFile, error) {
+	flags := os.O_RDWR
+	if create {
+		flags = flags | os.O_CREATE
+	}
+	file, err := os.OpenFile(s.dir+name, flags, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &diskFile{File: file}, nil
+}
+
+func (s *DiskStorage) Remove(name string) error {
+	return os.Remove(s.dir + name)
+}
+
+/*
+Rename replaces newName with oldName and syncs the directory, so the rename survives a crash
+*/
+func (s *DiskStorage) Rename(oldName string, newName string) error {
+	err := os.Rename(s.dir+oldName, s.dir+newName)
+	if err != nil {
+		return err
+	}
+	dir, err := os.Open(filepath.Dir(s.dir + newName))
+	if err != nil {
+		return err
+	}
+	err = dir.Sync()
+	closeErr := dir.Close()
+	if err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (s *DiskStorage) Exists(name string) (bool, error) {
+	_, err := os.Stat(s.dir + name)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+/*
+diskFile is an open file of a DiskStorage
+*/
+type diskFile struct {
+	*os.File
+}
+
+func (f *diskFile) Size() (int64, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
@@ -0,0 +1,12 @@
+//go:build !unix
+
+package src
+
+import "io"
+
+/*
+Lock does not lock anything, files cannot be locked on this platform
+*/
+func (s *DiskStorage) Lock(name string, exclusive bool) (io.Closer, error) {
+	return nopLock{}, nil
+}
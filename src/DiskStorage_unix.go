@@ -0,0 +1,86 @@
+//go:build unix
+
+package src
+
+import (
+	"io"
+	"os"
+	"syscall"
+)
+
+/*
+Lock takes an advisory flock on the file, the file is created if it does not exist yet and removed when the last lock is released
+*/
+func (s *DiskStorage) Lock(name string, exclusive bool) (io.Closer, error) {
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	for {
+		file, err := os.OpenFile(s.dir+name, os.O_RDONLY|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, err
+		}
+		err = syscall.Flock(int(file.Fd()), how|syscall.LOCK_NB)
+		if err == syscall.EWOULDBLOCK {
+			err = ErrLocked
+		}
+		if err != nil {
+			_ = file.Close()
+			return nil, err
+		}
+		// the file may have been removed by the previous holder before the lock has been taken
+		if sameFile(file, s.dir+name) {
+			return &diskLock{file: file, path: s.dir + name}, nil
+		}
+		_ = file.Close()
+	}
+}
+
+/*
+Map maps the first size bytes of the file read-only into memory
+*/
+func (f *diskFile) Map(size int64) ([]byte, error) {
+	return syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+}
+
+/*
+Unmap releases a mapping created by Map
+*/
+func (f *diskFile) Unmap(data []byte) error {
+	return syscall.Munmap(data)
+}
+
+/*
+sameFile reports if path still refers to the open file
+*/
+func sameFile(file *os.File, path string) bool {
+	opened, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	current, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(opened, current)
+}
+
+/*
+diskLock is a flock held on a lock file of a DiskStorage
+*/
+type diskLock struct {
+	file *os.File
+	path string
+}
+
+/*
+Close releases the lock, the lock file is removed if no one else holds a lock on it
+*/
+func (l *diskLock) Close() error {
+	if syscall.Flock(int(l.file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB) == nil {
+		_ = os.Remove(l.path)
+	}
+	// closing the file releases the lock
+	return l.file.Close()
+}
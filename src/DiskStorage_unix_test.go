@@ -0,0 +1,87 @@
+//go:build unix
+
+package src
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+/*
+TestDiskStorageLock tests that flocks on disk exclude a second writer and that the lock file is removed with the last lock
+*/
+func TestDiskStorageLock(t *testing.T) {
+	createFileWithPages(t, "testFileForFlock", 2)
+	defer func() {
+		_ = os.Remove("./testFileForFlock")
+	}()
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024))
+	var otherBuffer, _ = CreateNewBufferManager("./", uint64(1024))
+
+	pageID, err := myBuffer.Pin("testFileForFlock", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = myBuffer.Unpin(pageID, false)
+	if _, err := otherBuffer.Pin("testFileForFlock", 0); !errors.Is(err, ErrLocked) {
+		t.Fatalf("a second writer got %v instead of ErrLocked", err)
+	}
+	_ = myBuffer.Close()
+	if _, err := os.Stat("./testFileForFlock.lock"); !os.IsNotExist(err) {
+		t.Errorf("the lock file is still there after Close: %v", err)
+	}
+
+	storage := NewDiskStorage("./")
+	first, err := storage.Lock("testFileForFlock.lock", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := storage.Lock("testFileForFlock.lock", false)
+	if err != nil {
+		t.Fatalf("a second shared lock failed: %v", err)
+	}
+	if _, err := storage.Lock("testFileForFlock.lock", true); !errors.Is(err, ErrLocked) {
+		t.Errorf("an exclusive lock got %v while shared locks are held", err)
+	}
+	_ = first.Close()
+	if _, err := os.Stat("./testFileForFlock.lock"); err != nil {
+		t.Errorf("the lock file has been removed while it is still locked: %v", err)
+	}
+	_ = second.Close()
+	if _, err := os.Stat("./testFileForFlock.lock"); !os.IsNotExist(err) {
+		t.Errorf("the lock file is still there after the last lock has been released: %v", err)
+	}
+}
+
+/*
+TestDiskStorageDeleteRemovesLock tests that Delete fails for a file locked elsewhere and removes the lock file with the tree file
+*/
+func TestDiskStorageDeleteRemovesLock(t *testing.T) {
+	createFileWithPages(t, "testFileForDeleteLock", 2)
+	defer func() {
+		_ = os.Remove("./testFileForDeleteLock")
+	}()
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024))
+	var otherBuffer, _ = CreateNewBufferManager("./", uint64(1024))
+
+	pageID, _ := otherBuffer.Pin("testFileForDeleteLock", 0)
+	_ = otherBuffer.Unpin(pageID, false)
+	if err := myBuffer.Delete("testFileForDeleteLock"); !errors.Is(err, ErrLocked) {
+		t.Fatalf("deleting a file locked elsewhere returned %v instead of ErrLocked", err)
+	}
+	_ = otherBuffer.Close()
+
+	pageID, _ = myBuffer.Pin("testFileForDeleteLock", 0)
+	_ = myBuffer.Unpin(pageID, false)
+	err := myBuffer.Delete("testFileForDeleteLock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat("./testFileForDeleteLock.lock"); !os.IsNotExist(err) {
+		t.Errorf("the lock file is still there after Delete: %v", err)
+	}
+	if len(myBuffer.locks) != 0 {
+		t.Error("the deleted file is still locked")
+	}
+}
@@ -0,0 +1,149 @@
+package src
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"os"
+)
+
+/*
+Pages of binary files are not written in place directly, a page that is torn by a crash in the middle of the
+write could not be restored. Every batch of page images is first written to the double write buffer next to the
+tree file, then to its place in the file. The buffer is removed once the batch is in place.
+If the buffer is still there when the file is opened the batch is written again, a buffer that has not been
+written completely is discarded because the tree file has not been touched yet.
+This protects against a power loss only with WithSync, which orders the steps with a sync of the buffer and the file.
+Without it the operating system may write the steps in any order, a torn page is then only prevented for a crash of the process.
+
+The buffer holds the number of images, every image behind the offset it belongs to and the CRC32C checksum
+of everything before it.
+*/
+const doubleWriteRecordSize = 8 + PageSize
+
+/*
+doubleWriteRecord is one page image together with its place in the tree file
+*/
+type doubleWriteRecord struct {
+	offset int64
+	buf    []byte
+}
+
+/*
+doubleWriteName returns the name of the double write buffer of the given tree file
+*/
+func doubleWriteName(fileID string) string {
+	return fileID + ".dwb"
+}
+
+/*
+writeRecords writes the page images through the double write buffer into the file, compressed and encrypted files write them into slots
+*/
+func (f *binaryPageFile) writeRecords(records []doubleWriteRecord) error {
+	if f.slotted {
+		return f.writeSlots(records)
+	}
+	return f.writeInPlace(records, f.sync)
+}
+
+/*
+writeInPlace writes the page images through the double write buffer to their offset in the file.
+With sync set the buffer and the file are synced before the next step starts.
+*/
+func (f *binaryPageFile) writeInPlace(records []doubleWriteRecord, sync bool) error {
+	content := encodeDoubleWrite(records)
+	buffer, err := f.storage.Open(doubleWriteName(f.name), true)
+	if err != nil {
+		return err
+	}
+	err = buffer.Truncate(0)
+	if err == nil {
+		_, err = buffer.WriteAt(content, 0)
+	}
+	if err == nil && sync {
+		err = buffer.Sync()
+	}
+	closeErr := buffer.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = f.storage.Remove(doubleWriteName(f.name))
+		return err
+	}
+
+	// from here on the batch is restored on the next open if a write fails
+	for _, record := range records {
+		_, err = f.file.WriteAt(record.buf, record.offset)
+		if err != nil {
+			return err
+		}
+	}
+	if sync {
+		err = f.file.Sync()
+		if err != nil {
+			return err
+		}
+	}
+	return f.storage.Remove(doubleWriteName(f.name))
+}
+
+/*
+encodeDoubleWrite returns the content of the double write buffer for the page images
+*/
+func encodeDoubleWrite(records []doubleWriteRecord) []byte {
+	content := make([]byte, 8+len(records)*doubleWriteRecordSize+4)
+	binary.LittleEndian.PutUint64(content, uint64(len(records)))
+	for i, record := range records {
+		start := 8 + i*doubleWriteRecordSize
+		binary.LittleEndian.PutUint64(content[start:], uint64(record.offset))
+		copy(content[start+8:start+doubleWriteRecordSize], record.buf)
+	}
+	binary.LittleEndian.PutUint32(content[len(content)-4:], crc32.Checksum(content[:len(content)-4], castagnoli))
+	return content
+}
+
+/*
+replayDoubleWrite writes a batch that is left in the double write buffer of the file into it
+*/
+func replayDoubleWrite(storage Storage, file StorageFile, fileID string) error {
+	content, err := readStorageFile(storage, doubleWriteName(fileID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if validDoubleWrite(content) {
+		count := binary.LittleEndian.Uint64(content)
+		for i := uint64(0); i < count; i++ {
+			start := 8 + i*doubleWriteRecordSize
+			offset := int64(binary.LittleEndian.Uint64(content[start:]))
+			_, err = file.WriteAt(content[start+8:start+doubleWriteRecordSize], offset)
+			if err != nil {
+				return err
+			}
+		}
+		// the buffer may only go away once the batch is safely in the file
+		err = file.Sync()
+		if err != nil {
+			return err
+		}
+	}
+	return storage.Remove(doubleWriteName(fileID))
+}
+
+/*
+validDoubleWrite reports if the double write buffer has been written completely
+*/
+func validDoubleWrite(content []byte) bool {
+	if len(content) < 8+4 {
+		return false
+	}
+	count := binary.LittleEndian.Uint64(content)
+	if count > uint64(len(content))/doubleWriteRecordSize || uint64(len(content)) != 8+count*doubleWriteRecordSize+4 {
+		return false
+	}
+	return binary.LittleEndian.Uint32(content[len(content)-4:]) == crc32.Checksum(content[:len(content)-4], castagnoli)
+}
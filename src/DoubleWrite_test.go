@@ -0,0 +1,108 @@
+package src
+
+import (
+	"os"
+	"testing"
+)
+
+/*
+TestDoubleWriteReplayOnOpen tests that a complete batch left in the double write buffer is written into the file when it is opened
+*/
+func TestDoubleWriteReplayOnOpen(t *testing.T) {
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024), WithSync(true))
+	defer func() {
+		_ = os.Remove("./testFileForReplay")
+		_ = os.Remove("./" + doubleWriteName("testFileForReplay"))
+	}()
+	defer func() {
+		_ = myBuffer.Close()
+	}()
+	for i := 0; i < 2; i++ {
+		_, _ = myBuffer.AllocatePage("testFileForReplay")
+	}
+	file, _ := myBuffer.file("testFileForReplay", false)
+	binaryFile := file.(*openFile).pageFile.(*binaryPageFile)
+	records, _ := binaryFile.pageRecords([]Page{{pageId: 1, Keys: [6]uint64{5}, Values: [7]uint64{50}}})
+	_ = myBuffer.Close()
+
+	// simulate a crash after the double write buffer has been written
+	_ = os.WriteFile("./"+doubleWriteName("testFileForReplay"), encodeDoubleWrite(records), 0644)
+
+	pageID, err := myBuffer.Pin("testFileForReplay", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if myBuffer.Pages[pageID].Keys[0] != 5 || myBuffer.Pages[pageID].Values[0] != 50 {
+		t.Fatalf("page has not been restored from the double write buffer: %v", myBuffer.Pages[pageID])
+	}
+	_ = myBuffer.Unpin(pageID, false)
+	if _, err := os.Stat("./" + doubleWriteName("testFileForReplay")); !os.IsNotExist(err) {
+		t.Fatal("double write buffer has not been removed after the replay")
+	}
+}
+
+/*
+TestDoubleWriteDiscardsTornBuffer tests that an incomplete double write buffer leaves the file untouched
+*/
+func TestDoubleWriteDiscardsTornBuffer(t *testing.T) {
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024))
+	defer func() {
+		_ = os.Remove("./testFileForTornBuffer")
+		_ = os.Remove("./" + doubleWriteName("testFileForTornBuffer"))
+	}()
+	defer func() {
+		_ = myBuffer.Close()
+	}()
+	_, _ = myBuffer.AllocatePage("testFileForTornBuffer")
+	_ = myBuffer.Close()
+
+	records := []doubleWriteRecord{{offset: offset(0), buf: make([]byte, PageSize)}}
+	content := encodeDoubleWrite(records)
+	_ = os.WriteFile("./"+doubleWriteName("testFileForTornBuffer"), content[:len(content)/2], 0644)
+
+	pageID, err := myBuffer.Pin("testFileForTornBuffer", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = myBuffer.Unpin(pageID, false)
+	if _, err := os.Stat("./" + doubleWriteName("testFileForTornBuffer")); !os.IsNotExist(err) {
+		t.Fatal("torn double write buffer has not been removed")
+	}
+}
+
+/*
+TestDoubleWriteFlushReturnsError tests that a failing write is reported by Flush and the page stays dirty
+*/
+func TestDoubleWriteFlushReturnsError(t *testing.T) {
+	_ = os.Mkdir("./testDirForFlush", 0755)
+	defer func() {
+		_ = os.RemoveAll("./testDirForFlush")
+	}()
+	_ = os.WriteFile("./testDirForFlush/testFileForFlushError", []byte("1;;;;;;2;;;;;;"), 0644)
+
+	var myBuffer, _ = CreateNewBufferManager("./testDirForFlush/", uint64(1024))
+	pageID, err := myBuffer.Pin("testFileForFlushError", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	myBuffer.Pages[pageID].Values[0] = 3
+	_ = myBuffer.Unpin(pageID, true)
+
+	_ = os.RemoveAll("./testDirForFlush")
+	if myBuffer.Flush() == nil {
+		t.Fatal("flush into a removed directory should return an error but does not")
+	}
+	if !myBuffer.frames[pageID].dirty {
+		t.Fatal("page that could not be written is no longer dirty")
+	}
+
+	_ = os.Mkdir("./testDirForFlush", 0755)
+	err = myBuffer.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dat, _ := os.ReadFile("./testDirForFlush/testFileForFlushError")
+	if string(dat) != "1;;;;;;3;;;;;;" {
+		t.Fatalf("file contains %q after the second flush", string(dat))
+	}
+}
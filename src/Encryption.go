@@ -0,0 +1,184 @@
+package src
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+/*
+Encrypted files store every page in a slot of its own, sealed with AES-GCM (see Slots.go). The key of a file is
+derived from the key given with WithEncryptionKey and a random salt in the header, so files that share a key never
+share a page key. A slot holds the version of the page followed by the sealed page, the nonce is made of the
+version and the page and the page is authenticated as well, so a slot cannot be moved to another page unnoticed.
+
+Every write of a page takes a new version of the file and a nonce is never used twice with the same key.
+Versions are leased through the header before they are used, after a crash the file continues behind the lease.
+*/
+const (
+	saltSize     = 16
+	versionSize  = 8
+	versionLease = 1024 // versions reserved with one write of the header
+)
+
+/*
+ErrWrongKey is returned when an encrypted file is opened with another key than the one it has been created with
+*/
+var ErrWrongKey = errors.New("the file has been encrypted with another key")
+
+/*
+WithEncryptionKey creates new binary tree files with pages encrypted with AES-GCM and is needed to read encrypted files.
+The key has to be 16, 24 or 32 bytes long to select AES-128, AES-192 or AES-256. Existing files keep the format they have been created in.
+*/
+func WithEncryptionKey(key []byte) Option {
+	return func(bm *BufferManager) {
+		bm.encryptionKey = append([]byte(nil), key...)
+	}
+}
+
+/*
+pageCipher seals and opens the slots of one encrypted file
+*/
+type pageCipher struct {
+	aead     cipher.AEAD
+	salt     [saltSize]byte
+	keyCheck [sha256.Size / 2]byte // proves that a key belongs to the file without revealing it
+	next     uint64                // version of the next page that is written
+	lease    uint64                // versions from here on have not been used yet
+}
+
+/*
+createPageCipher creates the cipher of a new file with a fresh salt
+*/
+func createPageCipher(key []byte) (*pageCipher, error) {
+	var salt [saltSize]byte
+	_, err := rand.Read(salt[:])
+	if err != nil {
+		return nil, err
+	}
+	return newPageCipher(key, salt)
+}
+
+/*
+loadPageCipher creates the cipher of an existing file out of the parameters in its header in buf
+*/
+func loadPageCipher(fileID string, key []byte, buf []byte) (*pageCipher, error) {
+	var salt [saltSize]byte
+	copy(salt[:], buf[headerSaltOffset:])
+	c, err := newPageCipher(key, salt)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(c.keyCheck[:], buf[headerKeyCheckOffset:headerKeyCheckOffset+len(c.keyCheck)]) {
+		return nil, fmt.Errorf("%s: %w", fileID, ErrWrongKey)
+	}
+	// versions below the lease may have been used before a crash
+	c.lease = binary.LittleEndian.Uint64(buf[headerLeaseOffset:])
+	c.next = c.lease
+	return c, nil
+}
+
+/*
+newPageCipher derives the key of the file with the given salt
+*/
+func newPageCipher(key []byte, salt [saltSize]byte) (*pageCipher, error) {
+	c := &pageCipher{salt: salt}
+	block, err := aes.NewCipher(deriveKey(key, "page key", salt)[:len(key)])
+	if err != nil {
+		return nil, err
+	}
+	c.aead, err = cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	copy(c.keyCheck[:], deriveKey(key, "key check", salt))
+	return c, nil
+}
+
+/*
+deriveKey returns the HMAC-SHA256 of the purpose and the salt under the key
+*/
+func deriveKey(key []byte, purpose string, salt [saltSize]byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(purpose))
+	mac.Write(salt[:])
+	return mac.Sum(nil)
+}
+
+/*
+encodeParams stores the salt, the key check and the lease in the encoded header in buf, the checksum has to be renewed afterwards
+*/
+func (c *pageCipher) encodeParams(buf []byte) {
+	copy(buf[headerSaltOffset:], c.salt[:])
+	copy(buf[headerKeyCheckOffset:], c.keyCheck[:])
+	binary.LittleEndian.PutUint64(buf[headerLeaseOffset:], c.lease)
+}
+
+/*
+pageNonce returns the nonce for the given version of the page
+*/
+func pageNonce(pageInFile uint64, version uint64) []byte {
+	nonce := make([]byte, 12)
+	binary.LittleEndian.PutUint64(nonce, version)
+	binary.LittleEndian.PutUint32(nonce[versionSize:], uint32(pageInFile))
+	return nonce
+}
+
+/*
+pageData returns the additional data that binds a slot to its page
+*/
+func pageData(pageInFile uint64) []byte {
+	data := make([]byte, 8)
+	binary.LittleEndian.PutUint64(data, pageInFile)
+	return data
+}
+
+/*
+seal encrypts the page with the next version, the lease has to cover it
+*/
+func (c *pageCipher) seal(pageInFile uint64, data []byte) []byte {
+	version := c.next
+	c.next++
+	out := make([]byte, versionSize, versionSize+len(data)+c.aead.Overhead())
+	binary.LittleEndian.PutUint64(out, version)
+	return c.aead.Seal(out, pageNonce(pageInFile, version), data, pageData(pageInFile))
+}
+
+/*
+open decrypts the slot of the page, a slot that has been modified or belongs to another page fails the authentication
+*/
+func (c *pageCipher) open(pageInFile uint64, data []byte) ([]byte, error) {
+	if len(data) < versionSize+c.aead.Overhead() {
+		return nil, errors.New("the slot is too short to be encrypted")
+	}
+	version := binary.LittleEndian.Uint64(data)
+	plain, err := c.aead.Open(nil, pageNonce(pageInFile, version), data[versionSize:], pageData(pageInFile))
+	if err != nil {
+		return nil, errors.New("authentication failed")
+	}
+	return plain, nil
+}
+
+/*
+reserveVersions makes sure that the lease covers the versions of the next n pages, the header is written if it does not.
+The header is synced even without WithSync, a lease that is lost in a crash would hand out its versions a second time.
+*/
+func (f *binaryPageFile) reserveVersions(n uint64) error {
+	c := f.cipher
+	if c.next+n <= c.lease {
+		return nil
+	}
+	lease := c.lease
+	c.lease = c.next + n + versionLease
+	// the committed header and table are written again, only the lease changes
+	err := f.writeInPlace([]doubleWriteRecord{{offset: 0, buf: f.slottedHeader(f.slots.header, f.slots.table)}}, true)
+	if err != nil {
+		c.lease = lease
+	}
+	return err
+}
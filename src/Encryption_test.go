@@ -0,0 +1,192 @@
+package src
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+var testKey = []byte("0123456789abcdef0123456789abcdef")
+
+/*
+createEncryptedFile creates a file with encrypted pages whose first key is 0x1122334455667788 plus the page number
+*/
+func createEncryptedFile(t *testing.T, storage Storage, name string, pages int, options ...Option) {
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024), append([]Option{WithStorage(storage), WithEncryptionKey(testKey)}, options...)...)
+	for i := 0; i < pages; i++ {
+		pageInFile, err := myBuffer.AllocatePage(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pageID, err := myBuffer.Pin(name, pageInFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		myBuffer.Pages[pageID].Keys[0] = 0x1122334455667788 + pageInFile
+		_ = myBuffer.Unpin(pageID, true)
+	}
+	err := myBuffer.Flush()
+	if err == nil {
+		err = myBuffer.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+/*
+TestEncryptionRoundTrip tests that encrypted pages can only be read back with the right key and do not appear in the file
+*/
+func TestEncryptionRoundTrip(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		storage := NewMemoryStorage()
+		createEncryptedFile(t, storage, "testFileForEncryption", 3, WithCompression(compress))
+
+		dat, _ := readStorageFile(storage, "testFileForEncryption")
+		plain := make([]byte, 8)
+		binary.LittleEndian.PutUint64(plain, 0x1122334455667788)
+		if bytes.Contains(dat, plain) {
+			t.Error("the file contains a key of a page in plain text")
+		}
+
+		var myBuffer, _ = CreateNewBufferManager("./", uint64(1024), WithStorage(storage), WithEncryptionKey(testKey))
+		header, _ := myBuffer.Header("testFileForEncryption")
+		if !header.Encrypted || header.Compressed != compress {
+			t.Errorf("file has been created with the header %+v", header)
+		}
+		for pageInFile := uint64(0); pageInFile < 3; pageInFile++ {
+			pageID, err := myBuffer.Pin("testFileForEncryption", pageInFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if myBuffer.Pages[pageID].Keys[0] != 0x1122334455667788+pageInFile {
+				t.Errorf("page %d has the key %#x", pageInFile, myBuffer.Pages[pageID].Keys[0])
+			}
+			_ = myBuffer.Unpin(pageID, false)
+		}
+
+		_ = myBuffer.Close()
+		var noKey, _ = CreateNewBufferManager("./", uint64(1024), WithStorage(storage))
+		if _, err := noKey.Pin("testFileForEncryption", 0); err == nil {
+			t.Error("an encrypted file has been read without a key")
+		}
+		var wrongKey, _ = CreateNewBufferManager("./", uint64(1024), WithStorage(storage), WithEncryptionKey([]byte("fedcba9876543210")))
+		if _, err := wrongKey.Pin("testFileForEncryption", 0); !errors.Is(err, ErrWrongKey) {
+			t.Errorf("opening with the wrong key returned %v", err)
+		}
+	}
+}
+
+/*
+TestEncryptionDetectsTampering tests that a modified slot and a slot moved to another page fail the authentication
+*/
+func TestEncryptionDetectsTampering(t *testing.T) {
+	storage := NewFaultStorage(NewMemoryStorage())
+	createEncryptedFile(t, storage, "testFileForTampering", 3)
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024), WithStorage(storage), WithEncryptionKey(testKey))
+	file, err := myBuffer.openPageFile("testFileForTampering", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	binaryFile := file.(*binaryPageFile)
+	place := binaryFile.slots.slots[1]
+
+	// the slot of page 1 is read in place of page 2
+	binaryFile.slots.slots[2] = place
+	_, err = binaryFile.ReadPage(2)
+	var corruptionError *CorruptionError
+	if !errors.As(err, &corruptionError) || corruptionError.Page != 2 {
+		t.Errorf("a slot moved to another page returned %v", err)
+	}
+	_ = file.Close()
+
+	storage.FlipBit("testFileForTampering", place.end()-1, 0)
+	_, err = myBuffer.Pin("testFileForTampering", 1)
+	if !errors.As(err, &corruptionError) || corruptionError.Page != 1 {
+		t.Fatalf("expected a CorruptionError for page 1 but got %v", err)
+	}
+	_, err = myBuffer.Pin("testFileForTampering", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+/*
+TestEncryptionNeverReusesVersions tests that every write of a page uses a new version, also after the file has been reopened
+*/
+func TestEncryptionNeverReusesVersions(t *testing.T) {
+	storage := NewMemoryStorage()
+	createEncryptedFile(t, storage, "testFileForVersions", 1)
+	seen := make(map[uint64]bool)
+	for i := uint64(0); i < 5; i++ {
+		var myBuffer, _ = CreateNewBufferManager("./", uint64(1024), WithStorage(storage), WithEncryptionKey(testKey))
+		pageID, err := myBuffer.Pin("testFileForVersions", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		myBuffer.Pages[pageID].Values[0] = i
+		_ = myBuffer.Unpin(pageID, true)
+		err = myBuffer.Flush()
+		if err == nil {
+			err = myBuffer.Close()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		file, _ := myBuffer.openPageFile("testFileForVersions", false)
+		binaryFile := file.(*binaryPageFile)
+		data := make([]byte, versionSize)
+		_, _ = binaryFile.file.ReadAt(data, binaryFile.slots.slots[0].offset)
+		_ = file.Close()
+		version := binary.LittleEndian.Uint64(data)
+		if seen[version] {
+			t.Fatalf("version %d has been used twice", version)
+		}
+		seen[version] = true
+	}
+}
+
+/*
+TestEncryptionSyncsLease tests that a new lease reaches the disk without WithSync while writes within the lease are not synced
+*/
+func TestEncryptionSyncsLease(t *testing.T) {
+	storage := NewFaultStorage(NewMemoryStorage())
+	createEncryptedFile(t, storage, "testFileForLease", 1)
+	syncs := storage.Syncs()
+	if syncs == 0 {
+		t.Fatal("the lease has been written without a sync")
+	}
+
+	// a restart continues behind the lease and needs a new one, the second write is covered by it
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024), WithStorage(storage), WithEncryptionKey(testKey))
+	defer myBuffer.Close()
+	for round := 0; round < 2; round++ {
+		pageID, err := myBuffer.Pin("testFileForLease", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = myBuffer.Unpin(pageID, true)
+		err = myBuffer.Flush()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if round == 0 && storage.Syncs() == syncs {
+			t.Fatal("the new lease has been written without a sync")
+		}
+		if round == 1 && storage.Syncs() != 2*syncs {
+			t.Fatalf("a write within the lease has been synced, %d syncs instead of %d", storage.Syncs(), 2*syncs)
+		}
+	}
+}
+
+/*
+TestEncryptionRejectsInvalidKeys tests that keys of the wrong size are rejected when the BufferManager is created
+*/
+func TestEncryptionRejectsInvalidKeys(t *testing.T) {
+	_, err := CreateNewBufferManager("./", uint64(1024), WithEncryptionKey([]byte("short")))
+	if err == nil {
+		t.Error("a key of 5 bytes has been accepted")
+	}
+}
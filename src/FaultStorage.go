@@ -0,0 +1,291 @@
+package src
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+/*
+ErrInjectedFault is returned by a FaultStorage for reads and writes that have been programmed to fail
+*/
+var ErrInjectedFault = errors.New("injected fault")
+
+/*
+ErrCrashed is returned by a FaultStorage for every change after the simulated crash
+*/
+var ErrCrashed = errors.New("storage has crashed")
+
+/*
+FaultStorage wraps a Storage and injects faults into the I/O going through it, so the behaviour of a
+BufferManager under disk faults can be tested. Faults for a name only hit the file with that name,
+the empty name hits every file. After a simulated crash nothing reaches the wrapped Storage anymore,
+a new BufferManager on the wrapped Storage sees the files as they were at the time of the crash.
+*/
+type FaultStorage struct {
+	inner       Storage
+	mu          sync.Mutex
+	failReads   map[string]bool
+	failWrites  map[string]bool
+	shortWrites map[string]int // number of bytes that are written before a write fails
+	bitFlips    map[string][]bitFlip
+	crashAfter  int // number of writes that still succeed before the crash, -1 if no crash is planned
+	writes      int // number of successful writes
+	syncs       int // number of successful syncs
+}
+
+/*
+bitFlip flips one bit of the byte at offset whenever it is read
+*/
+type bitFlip struct {
+	offset int64
+	mask   byte
+}
+
+/*
+NewFaultStorage wraps the given Storage without any fault programmed
+*/
+func NewFaultStorage(inner Storage) *FaultStorage {
+	s := &FaultStorage{inner: inner}
+	s.Heal()
+	return s
+}
+
+/*
+FailReads makes every read of the named file fail with ErrInjectedFault
+*/
+func (s *FaultStorage) FailReads(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failReads[name] = true
+}
+
+/*
+FailWrites makes every write and sync of the named file fail with ErrInjectedFault
+*/
+func (s *FaultStorage) FailWrites(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failWrites[name] = true
+}
+
+/*
+ShortWrites makes every write to the named file stop after n bytes and return io.ErrShortWrite
+*/
+func (s *FaultStorage) ShortWrites(name string, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.shortWrites[name] = n
+}
+
+/*
+FlipBit flips the given bit of the byte at offset whenever it is read from the named file, the stored data is not changed
+*/
+func (s *FaultStorage) FlipBit(name string, offset int64, bit uint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bitFlips[name] = append(s.bitFlips[name], bitFlip{offset: offset, mask: 1 << (bit % 8)})
+}
+
+/*
+CrashAfter lets the next n writes succeed, every change after them fails with ErrCrashed and is lost
+*/
+func (s *FaultStorage) CrashAfter(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.crashAfter = n
+}
+
+/*
+Heal removes every programmed fault, a crash that has already happened is undone for following changes
+*/
+func (s *FaultStorage) Heal() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failReads = make(map[string]bool)
+	s.failWrites = make(map[string]bool)
+	s.shortWrites = make(map[string]int)
+	s.bitFlips = make(map[string][]bitFlip)
+	s.crashAfter = -1
+}
+
+/*
+Writes returns the number of writes that have reached the wrapped Storage
+*/
+func (s *FaultStorage) Writes() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.writes
+}
+
+/*
+Syncs returns the number of syncs that have reached the wrapped Storage
+*/
+func (s *FaultStorage) Syncs() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.syncs
+}
+
+/*
+Crashed reports if the simulated crash has happened
+*/
+func (s *FaultStorage) Crashed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.crashAfter == 0
+}
+
+func (s *FaultStorage) Open(name string, create bool) (StorageFile, error) {
+	if create {
+		if exists, err := s.inner.Exists(name); err == nil && !exists && s.Crashed() {
+			return nil, ErrCrashed
+		}
+	}
+	file, err := s.inner.Open(name, create)
+	if err != nil {
+		return nil, err
+	}
+	return &faultFile{StorageFile: file, storage: s, name: name}, nil
+}
+
+func (s *FaultStorage) Remove(name string) error {
+	if s.Crashed() {
+		return ErrCrashed
+	}
+	return s.inner.Remove(name)
+}
+
+func (s *FaultStorage) Rename(oldName string, newName string) error {
+	if s.Crashed() {
+		return ErrCrashed
+	}
+	return s.inner.Rename(oldName, newName)
+}
+
+func (s *FaultStorage) Exists(name string) (bool, error) {
+	return s.inner.Exists(name)
+}
+
+/*
+Lock locks the file in the inner Storage if it can lock files
+*/
+func (s *FaultStorage) Lock(name string, exclusive bool) (io.Closer, error) {
+	locker, ok := s.inner.(Locker)
+	if !ok {
+		return nopLock{}, nil
+	}
+	return locker.Lock(name, exclusive)
+}
+
+/*
+matches reports if a fault programmed for the given name hits the file
+*/
+func matches(name string, file string) bool {
+	return name == "" || name == file
+}
+
+/*
+writeFault returns the error of the next write to the named file and how many of its bytes reach the file,
+-1 if all of them do. A write that succeeds counts towards a planned crash.
+*/
+func (s *FaultStorage) writeFault(file string, size int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.crashAfter == 0 {
+		return 0, ErrCrashed
+	}
+	for name := range s.failWrites {
+		if matches(name, file) {
+			return 0, ErrInjectedFault
+		}
+	}
+	for name, n := range s.shortWrites {
+		if matches(name, file) && n < size {
+			return n, io.ErrShortWrite
+		}
+	}
+	if s.crashAfter > 0 {
+		s.crashAfter--
+	}
+	s.writes++
+	return -1, nil
+}
+
+/*
+faultFile is an open file of a FaultStorage
+*/
+type faultFile struct {
+	StorageFile
+	storage *FaultStorage
+	name    string
+}
+
+func (f *faultFile) ReadAt(p []byte, off int64) (int, error) {
+	f.storage.mu.Lock()
+	var flips []bitFlip
+	for name, fileFlips := range f.storage.bitFlips {
+		if matches(name, f.name) {
+			flips = append(flips, fileFlips...)
+		}
+	}
+	failed := false
+	for name := range f.storage.failReads {
+		failed = failed || matches(name, f.name)
+	}
+	f.storage.mu.Unlock()
+	if failed {
+		return 0, ErrInjectedFault
+	}
+
+	n, err := f.StorageFile.ReadAt(p, off)
+	for _, flip := range flips {
+		if flip.offset >= off && flip.offset < off+int64(n) {
+			p[flip.offset-off] ^= flip.mask
+		}
+	}
+	return n, err
+}
+
+func (f *faultFile) WriteAt(p []byte, off int64) (int, error) {
+	n, err := f.storage.writeFault(f.name, len(p))
+	if n < 0 {
+		return f.StorageFile.WriteAt(p, off)
+	}
+	if n > 0 {
+		written, writeErr := f.StorageFile.WriteAt(p[:n], off)
+		if writeErr != nil {
+			return written, writeErr
+		}
+	}
+	return n, err
+}
+
+func (f *faultFile) Truncate(size int64) error {
+	if f.storage.Crashed() {
+		return ErrCrashed
+	}
+	return f.StorageFile.Truncate(size)
+}
+
+func (f *faultFile) Sync() error {
+	if f.storage.Crashed() {
+		return ErrCrashed
+	}
+	f.storage.mu.Lock()
+	failed := false
+	for name := range f.storage.failWrites {
+		failed = failed || matches(name, f.name)
+	}
+	f.storage.mu.Unlock()
+	if failed {
+		return ErrInjectedFault
+	}
+	err := f.StorageFile.Sync()
+	if err == nil {
+		f.storage.mu.Lock()
+		f.storage.syncs++
+		f.storage.mu.Unlock()
+	}
+	return err
+}
@@ -0,0 +1,147 @@
+package src
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+/*
+createTreeInStorage writes a binary tree with the root in page 0 and two leaves into the storage
+*/
+func createTreeInStorage(t *testing.T, storage Storage, name string) {
+	file, _ := storage.Open(name, true)
+	_, _ = file.WriteAt([]byte("10;;;;;;1;2;;;;;\n1;;;;;;2;;;;;;\n11;;;;;;12;;;;;;"), 0)
+	_ = file.Close()
+	myConverter := Converter{Storage: storage}
+	err := myConverter.TextToBinary(name, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+/*
+TestFaultStorageReadAndWriteFaults tests that failing reads and writes are reported by Pin and Flush and do not lose modifications
+*/
+func TestFaultStorageReadAndWriteFaults(t *testing.T) {
+	storage := NewFaultStorage(NewMemoryStorage())
+	createTreeInStorage(t, storage, "testFileForFaults")
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024), WithStorage(storage))
+
+	storage.FailReads("testFileForFaults")
+	_, err := myBuffer.Pin("testFileForFaults", 1)
+	if !errors.Is(err, ErrInjectedFault) {
+		t.Fatalf("Pin returned %v instead of the injected fault", err)
+	}
+	storage.Heal()
+	pageID, err := myBuffer.Pin("testFileForFaults", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	myBuffer.Pages[pageID].Values[0] = 3
+	_ = myBuffer.Unpin(pageID, true)
+
+	storage.FailWrites("")
+	if !errors.Is(myBuffer.Flush(), ErrInjectedFault) {
+		t.Fatal("Flush did not return the injected fault")
+	}
+	storage.Heal()
+	storage.ShortWrites("testFileForFaults", 10)
+	if !errors.Is(myBuffer.Flush(), io.ErrShortWrite) {
+		t.Fatal("Flush did not return the short write")
+	}
+	if !myBuffer.frames[pageID].dirty {
+		t.Fatal("page that could not be written is no longer dirty")
+	}
+
+	// the torn page is restored from the double write buffer when the file is opened again
+	storage.Heal()
+	_ = myBuffer.Close()
+	var otherBuffer, _ = CreateNewBufferManager("./", uint64(1024), WithStorage(storage))
+	pageID, err = otherBuffer.Pin("testFileForFaults", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if otherBuffer.Pages[pageID].Values[0] != 3 {
+		t.Fatalf("page has value %d instead of 3 after the short write", otherBuffer.Pages[pageID].Values[0])
+	}
+}
+
+/*
+TestFaultStorageBitFlip tests that a bit flipped on the way from the disk is reported as corruption
+*/
+func TestFaultStorageBitFlip(t *testing.T) {
+	storage := NewFaultStorage(NewMemoryStorage())
+	createTreeInStorage(t, storage, "testFileForFlip")
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024), WithStorage(storage))
+
+	storage.FlipBit("testFileForFlip", offset(2)+valuesOffset, 3)
+	_, err := myBuffer.Pin("testFileForFlip", 2)
+	var corruptionError *CorruptionError
+	if !errors.As(err, &corruptionError) || corruptionError.Page != 2 {
+		t.Fatalf("expected a CorruptionError for page 2 but got %v", err)
+	}
+	_, err = myBuffer.Pin("testFileForFlip", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+/*
+TestFaultStorageCrash tests that a crash after any write leaves a tree that can be loaded,
+a flush is either completely on disk or not at all and the free list stays valid
+*/
+func TestFaultStorageCrash(t *testing.T) {
+	run := func(storage Storage) {
+		var myBuffer, _ = CreateNewBufferManager("./", uint64(1024), WithStorage(storage))
+		defer func() {
+			_ = myBuffer.Close()
+		}()
+		var myLoader = Loader{}
+		tree, err := myLoader.Load("testFileForCrash", myBuffer)
+		if err != nil {
+			return
+		}
+		_ = tree.Push(12, 13)
+		_ = tree.Push(3, 4)
+		_ = myBuffer.Flush()
+		pageInFile, _ := myBuffer.AllocatePage("testFileForCrash")
+		_ = myBuffer.FreePage("testFileForCrash", pageInFile)
+	}
+
+	inner := NewMemoryStorage()
+	createTreeInStorage(t, inner, "testFileForCrash")
+	storage := NewFaultStorage(inner)
+	run(storage)
+	writes := storage.Writes()
+
+	for crashAfter := 0; crashAfter <= writes; crashAfter++ {
+		inner := NewMemoryStorage()
+		createTreeInStorage(t, inner, "testFileForCrash")
+		storage := NewFaultStorage(inner)
+		storage.CrashAfter(crashAfter)
+		run(storage)
+
+		var myBuffer, _ = CreateNewBufferManager("./", uint64(1024), WithStorage(inner))
+		var myLoader = Loader{}
+		tree, err := myLoader.Load("testFileForCrash", myBuffer)
+		if err != nil {
+			t.Fatalf("crash after %d writes: %v", crashAfter, err)
+		}
+		for key, expected := range map[uint64]uint64{1: 2, 11: 12} {
+			value, err := tree.Get(key)
+			if err != nil || value != expected {
+				t.Fatalf("crash after %d writes: Get(%d) returned %d, %v instead of %d", crashAfter, key, value, err, expected)
+			}
+		}
+		first, _ := tree.Get(12)
+		second, _ := tree.Get(3)
+		if (first == 13) != (second == 4) {
+			t.Fatalf("crash after %d writes: only part of the flush is on disk", crashAfter)
+		}
+		err = myBuffer.CheckFreeList("testFileForCrash")
+		if err != nil {
+			t.Fatalf("crash after %d writes: %v", crashAfter, err)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package src
+
+import (
+	"errors"
+	"fmt"
+)
+
+/*
+openFile is an entry in the table of open tree files of a BufferManager
+*/
+type openFile struct {
+	pageFile
+	lastUsed uint64 // fileTick of the last access
+	users    int    // number of reads and page views that use the file without the latch of the page table
+}
+
+/*
+Open opens the given tree file and keeps it open for following pins and write backs until it is closed.
+Opening a file that is already open is a no-op.
+*/
+func (bm *BufferManager) Open(fileID string) error {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	_, err := bm.file(fileID, false)
+	return err
+}
+
+/*
+CloseFile closes the handle of the given tree file. Its cached pages stay in the buffer,
+the file is opened again when one of them has to be read or written.
+*/
+func (bm *BufferManager) CloseFile(fileID string) error {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	return bm.closeFile(fileID)
+}
+
+/*
+closeFile closes the handle of the given tree file
+*/
+func (bm *BufferManager) closeFile(fileID string) error {
+	file, ok := bm.files[fileID]
+	if !ok {
+		return fmt.Errorf("%s is not open", fileID)
+	}
+	if file.users > 0 {
+		return fmt.Errorf("%s is in use and cannot be closed", fileID)
+	}
+	delete(bm.files, fileID)
+	return file.Close()
+}
+
+/*
+Close stops the background flusher, waits for running read aheads, writes every dirty page to disk, closes every open tree file and releases the locks.
+An error of the background flusher that has not been reported yet or of the final flush is returned, otherwise pages that are still pinned are reported with ErrLeakedPins.
+*/
+func (bm *BufferManager) Close() error {
+	// the flusher and the read aheads need the lock to finish
+	bm.stopFlusher()
+	bm.prefetching.Wait()
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	firstErr := bm.flusher.takeError()
+	// pages of files that have left the file table reopen them here
+	err := bm.flush(func(key PageKey) bool { return true })
+	if firstErr == nil {
+		firstErr = err
+	}
+	if firstErr == nil {
+		firstErr = bm.leakedPins()
+	}
+	if len(bm.files) == 0 && len(bm.locks) == 0 {
+		if firstErr != nil {
+			return firstErr
+		}
+		return errors.New("no file to close")
+	}
+	for fileID := range bm.files {
+		err := bm.closeFile(fileID)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	for fileID := range bm.locks {
+		err := bm.unlock(fileID)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+/*
+file returns the open handle of the given tree file and opens it if needed.
+When the table is full the least recently used file is closed first, files that are in use are skipped.
+If all of them are in use the table grows beyond its limit for a while.
+*/
+func (bm *BufferManager) file(fileID string, create bool) (pageFile, error) {
+	bm.fileTick++
+	if file, ok := bm.files[fileID]; ok {
+		file.lastUsed = bm.fileTick
+		return file, nil
+	}
+
+	if len(bm.files) >= bm.maxOpenFiles {
+		var victim *openFile
+		victimID := ""
+		for id, file := range bm.files {
+			if file.users == 0 && (victim == nil || file.lastUsed < victim.lastUsed) {
+				victim = file
+				victimID = id
+			}
+		}
+		if victim != nil {
+			err := bm.closeFile(victimID)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// the lock is kept when the handle is closed, it is only released by Close
+	_, locked := bm.locks[fileID]
+	err := bm.lock(fileID)
+	if err != nil {
+		return nil, err
+	}
+	handle, err := bm.openPageFile(fileID, create && !bm.readOnly)
+	if err != nil {
+		if !locked {
+			_ = bm.unlock(fileID)
+		}
+		return nil, err
+	}
+	file := &openFile{pageFile: handle, lastUsed: bm.fileTick}
+	bm.files[fileID] = file
+	return file, nil
+}
@@ -0,0 +1,82 @@
+package src
+
+import (
+	"os"
+	"strconv"
+	"testing"
+)
+
+/*
+TestFileTableKeepsHandleOpen tests that pins are served through the open handle instead of reopening the file
+*/
+func TestFileTableKeepsHandleOpen(t *testing.T) {
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024))
+	_, _ = myBuffer.AllocatePage("testFileForHandle")
+	_, _ = myBuffer.AllocatePage("testFileForHandle")
+	_ = os.Remove("./testFileForHandle")
+	_ = os.Remove("./" + freeListName("testFileForHandle"))
+
+	_, err := myBuffer.Pin("testFileForHandle", 1)
+	if err != nil {
+		t.Fatalf("pin did not use the open handle: %v", err)
+	}
+
+	err = myBuffer.CloseFile("testFileForHandle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = myBuffer.CloseFile("testFileForHandle"); err == nil {
+		t.Fatal("closing a file twice should return an error but does not")
+	}
+	_, err = myBuffer.Pin("testFileForHandle", 0)
+	if err == nil {
+		t.Fatal("pin should reopen the closed file and fail since it has been removed")
+	}
+	_ = myBuffer.Close()
+}
+
+/*
+TestFileTableLimit tests that no more than the configured number of files are kept open
+*/
+func TestFileTableLimit(t *testing.T) {
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024), WithMaxOpenFiles(2))
+	for i := 0; i < 4; i++ {
+		name := "testFileForLimit" + strconv.Itoa(i)
+		createFileWithPages(t, name, 1)
+		defer func() {
+			_ = os.Remove("./" + name)
+		}()
+	}
+
+	for round := 0; round < 2; round++ {
+		for i := 0; i < 4; i++ {
+			id, err := myBuffer.Pin("testFileForLimit"+strconv.Itoa(i), 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = myBuffer.Unpin(id, round == 0)
+			if len(myBuffer.files) > 2 {
+				t.Fatalf("%d files are open instead of at most 2", len(myBuffer.files))
+			}
+		}
+	}
+
+	err := myBuffer.Flush()
+	if err != nil {
+		t.Fatalf("flushing pages of closed files failed: %v", err)
+	}
+	err = myBuffer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(myBuffer.files) != 0 {
+		t.Fatal("Close has not closed every file")
+	}
+	if err = myBuffer.Close(); err == nil {
+		t.Fatal("Close without open files should return an error but does not")
+	}
+
+	if _, err = CreateNewBufferManager("./", uint64(1024), WithMaxOpenFiles(0)); err == nil {
+		t.Fatal("a limit of zero open files should return an error but does not")
+	}
+}
@@ -0,0 +1,207 @@
+package src
+
+import (
+	"fmt"
+	"time"
+)
+
+/*
+flusher is the configuration and state of the goroutine that writes dirty pages in the background.
+Only pages that are not pinned are written, pinned pages may be modified at the same time.
+*/
+type flusher struct {
+	interval           time.Duration // dirty pages are written at this interval, 0 disables it
+	threshold          int           // dirty pages are written once this many pages are dirty, 0 disables it
+	checkpointInterval time.Duration // a checkpoint is taken at this interval, 0 disables it
+	wake               chan struct{} // signals that the threshold has been reached
+	stop               chan struct{} // closed to stop the goroutine
+	done               chan struct{} // closed by the goroutine when it has stopped
+	err                error         // first error of the goroutine that has not been reported yet
+	checkpoints        uint64        // number of checkpoints taken
+	lastCheckpoint     time.Time     // time of the last checkpoint
+}
+
+/*
+WithBackgroundFlush starts a goroutine that writes the dirty pages every interval and as soon as threshold pages are dirty.
+Either of them can be 0 to disable it. Close stops the goroutine after it has written the remaining dirty pages.
+*/
+func WithBackgroundFlush(interval time.Duration, threshold int) Option {
+	return func(bm *BufferManager) {
+		bm.flusher.interval = interval
+		bm.flusher.threshold = threshold
+	}
+}
+
+/*
+WithCheckpointInterval makes the background goroutine take a checkpoint every interval, see Checkpoint
+*/
+func WithCheckpointInterval(interval time.Duration) Option {
+	return func(bm *BufferManager) {
+		bm.flusher.checkpointInterval = interval
+	}
+}
+
+/*
+validate rejects a configuration the goroutine cannot run with
+*/
+func (f *flusher) validate() error {
+	if f.interval < 0 || f.checkpointInterval < 0 {
+		return fmt.Errorf("background flush intervals cannot be negative, got %v and %v", f.interval, f.checkpointInterval)
+	}
+	if f.threshold < 0 {
+		return fmt.Errorf("dirty page threshold cannot be negative, got %d", f.threshold)
+	}
+	return nil
+}
+
+/*
+enabled reports if the goroutine has something to do
+*/
+func (f *flusher) enabled() bool {
+	return f.interval > 0 || f.threshold > 0 || f.checkpointInterval > 0
+}
+
+/*
+notify wakes the goroutine up once the number of dirty pages has reached the threshold
+*/
+func (f *flusher) notify(dirtyCount int) {
+	if f.threshold == 0 || dirtyCount < f.threshold || f.wake == nil {
+		return
+	}
+	select {
+	case f.wake <- struct{}{}:
+	default:
+		// the goroutine has already been woken up
+	}
+}
+
+/*
+record keeps the first error of the goroutine until it is reported
+*/
+func (f *flusher) record(err error) {
+	if err != nil && f.err == nil {
+		f.err = err
+	}
+}
+
+/*
+takeError returns the error of the goroutine that has not been reported yet
+*/
+func (f *flusher) takeError() error {
+	err := f.err
+	f.err = nil
+	return err
+}
+
+/*
+startFlusher starts the goroutine if it has been configured
+*/
+func (bm *BufferManager) startFlusher() {
+	if !bm.flusher.enabled() {
+		return
+	}
+	bm.flusher.wake = make(chan struct{}, 1)
+	bm.flusher.stop = make(chan struct{})
+	bm.flusher.done = make(chan struct{})
+	go bm.runFlusher(bm.flusher.stop, bm.flusher.done)
+}
+
+/*
+stopFlusher stops the goroutine and waits until it has written the remaining dirty pages
+*/
+func (bm *BufferManager) stopFlusher() {
+	bm.mu.Lock()
+	stop, done := bm.flusher.stop, bm.flusher.done
+	bm.flusher.stop, bm.flusher.done = nil, nil
+	bm.mu.Unlock()
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+}
+
+/*
+runFlusher is the loop of the background goroutine
+*/
+func (bm *BufferManager) runFlusher(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	var flushTick, checkpointTick <-chan time.Time
+	if bm.flusher.interval > 0 {
+		ticker := time.NewTicker(bm.flusher.interval)
+		defer ticker.Stop()
+		flushTick = ticker.C
+	}
+	if bm.flusher.checkpointInterval > 0 {
+		ticker := time.NewTicker(bm.flusher.checkpointInterval)
+		defer ticker.Stop()
+		checkpointTick = ticker.C
+	}
+
+	for {
+		select {
+		case <-stop:
+			bm.backgroundFlush()
+			return
+		case <-flushTick:
+			bm.backgroundFlush()
+		case <-bm.flusher.wake:
+			bm.backgroundFlush()
+		case <-checkpointTick:
+			bm.mu.Lock()
+			bm.flusher.record(bm.checkpoint())
+			bm.mu.Unlock()
+		}
+	}
+}
+
+/*
+backgroundFlush writes the dirty pages that are not pinned
+*/
+func (bm *BufferManager) backgroundFlush() {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	bm.flusher.record(bm.flush(bm.unpinned))
+}
+
+/*
+unpinned selects the pages that are not pinned
+*/
+func (bm *BufferManager) unpinned(key PageKey) bool {
+	return bm.frames[bm.PageMap[key]].pinCount == 0
+}
+
+/*
+Checkpoint writes every dirty page that is not pinned and syncs the open tree files, so everything
+written up to now survives a crash even without WithSync. Pinned pages are written by a later flush.
+*/
+func (bm *BufferManager) Checkpoint() error {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	return bm.checkpoint()
+}
+
+func (bm *BufferManager) checkpoint() error {
+	err := bm.flush(bm.unpinned)
+	if err != nil {
+		return err
+	}
+	for _, file := range bm.files {
+		err = file.Sync()
+		if err != nil {
+			return err
+		}
+	}
+	bm.flusher.checkpoints++
+	bm.flusher.lastCheckpoint = time.Now()
+	return nil
+}
+
+/*
+LastCheckpoint returns the number of checkpoints taken so far and the time of the last one
+*/
+func (bm *BufferManager) LastCheckpoint() (uint64, time.Time) {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	return bm.flusher.checkpoints, bm.flusher.lastCheckpoint
+}
@@ -0,0 +1,169 @@
+package src
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+/*
+waitFor polls the condition until it holds and fails the test if it does not within a second
+*/
+func waitFor(t *testing.T, condition func() bool) {
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition did not hold within a second")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+/*
+modifyPage pins the page, changes its first value and unpins it as dirty
+*/
+func modifyPage(t *testing.T, myBuffer *BufferManager, fileID string, pageInFile uint64, value uint64) {
+	pageID, err := myBuffer.Pin(fileID, pageInFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	myBuffer.mu.Lock()
+	myBuffer.Pages[pageID].Values[0] = value
+	myBuffer.mu.Unlock()
+	_ = myBuffer.Unpin(pageID, true)
+}
+
+/*
+clean reports if the BufferManager has no dirty pages left
+*/
+func clean(myBuffer *BufferManager) func() bool {
+	return func() bool {
+		myBuffer.mu.Lock()
+		defer myBuffer.mu.Unlock()
+		return myBuffer.dirtyCount == 0
+	}
+}
+
+/*
+TestFlusherThreshold tests that the dirty pages are written as soon as the threshold is reached
+*/
+func TestFlusherThreshold(t *testing.T) {
+	storage := NewMemoryStorage()
+	createTreeInStorage(t, storage, "testFileForThreshold")
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024), WithStorage(storage), WithBackgroundFlush(time.Hour, 2))
+	defer func() {
+		_ = myBuffer.Close()
+	}()
+
+	modifyPage(t, myBuffer, "testFileForThreshold", 1, 3)
+	time.Sleep(10 * time.Millisecond)
+	if clean(myBuffer)() {
+		t.Fatal("page has been written before the threshold was reached")
+	}
+	modifyPage(t, myBuffer, "testFileForThreshold", 2, 13)
+	waitFor(t, clean(myBuffer))
+
+	dat, _ := readStorageFile(storage, "testFileForThreshold")
+	if page := decodePage(dat[offset(2):]); page.Values[0] != 13 {
+		t.Fatalf("page has value %d on disk instead of 13", page.Values[0])
+	}
+}
+
+/*
+TestFlusherIntervalSkipsPinnedPages tests that the dirty pages are written at the interval unless they are pinned
+*/
+func TestFlusherIntervalSkipsPinnedPages(t *testing.T) {
+	storage := NewMemoryStorage()
+	createTreeInStorage(t, storage, "testFileForInterval")
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024), WithStorage(storage), WithBackgroundFlush(time.Millisecond, 0))
+	defer func() {
+		_ = myBuffer.Close()
+	}()
+
+	modifyPage(t, myBuffer, "testFileForInterval", 1, 3)
+	pageID, _ := myBuffer.Pin("testFileForInterval", 1)
+	_ = myBuffer.Unpin(pageID, true)
+	_, _ = myBuffer.Pin("testFileForInterval", 1)
+	time.Sleep(10 * time.Millisecond)
+	if clean(myBuffer)() {
+		t.Fatal("pinned page has been written in the background")
+	}
+	_ = myBuffer.Unpin(pageID, false)
+	waitFor(t, clean(myBuffer))
+}
+
+/*
+TestFlusherCloseWritesRemainingPages tests that Close stops the goroutine after writing the dirty pages
+*/
+func TestFlusherCloseWritesRemainingPages(t *testing.T) {
+	storage := NewMemoryStorage()
+	createTreeInStorage(t, storage, "testFileForStop")
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024), WithStorage(storage), WithBackgroundFlush(time.Hour, 0))
+	done := myBuffer.flusher.done
+
+	modifyPage(t, myBuffer, "testFileForStop", 1, 3)
+	err := myBuffer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-done:
+	default:
+		t.Fatal("background goroutine is still running after Close")
+	}
+	if !clean(myBuffer)() {
+		t.Fatal("dirty page has not been written when the goroutine stopped")
+	}
+}
+
+/*
+TestFlusherCheckpointAndErrors tests that checkpoints are taken at the interval and that an error of
+the background goroutine is returned by the next Flush
+*/
+func TestFlusherCheckpointAndErrors(t *testing.T) {
+	storage := NewFaultStorage(NewMemoryStorage())
+	createTreeInStorage(t, storage, "testFileForCheckpoint")
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024), WithStorage(storage), WithCheckpointInterval(time.Millisecond))
+	defer func() {
+		_ = myBuffer.Close()
+	}()
+
+	modifyPage(t, myBuffer, "testFileForCheckpoint", 1, 3)
+	waitFor(t, func() bool {
+		count, last := myBuffer.LastCheckpoint()
+		return count > 0 && !last.IsZero()
+	})
+	if !clean(myBuffer)() {
+		t.Fatal("checkpoint has not written the dirty page")
+	}
+
+	storage.FailWrites("")
+	modifyPage(t, myBuffer, "testFileForCheckpoint", 2, 13)
+	count, _ := myBuffer.LastCheckpoint()
+	time.Sleep(10 * time.Millisecond)
+	if after, _ := myBuffer.LastCheckpoint(); after != count {
+		t.Fatal("checkpoint has been counted although the page could not be written")
+	}
+	storage.Heal()
+	err := myBuffer.Flush()
+	if !errors.Is(err, ErrInjectedFault) {
+		t.Fatalf("Flush returned %v instead of the error of the background goroutine", err)
+	}
+	if myBuffer.Flush() != nil {
+		t.Fatal("error of the background goroutine has been returned twice")
+	}
+}
+
+/*
+TestFlusherWithError tests that a negative configuration is rejected
+*/
+func TestFlusherWithError(t *testing.T) {
+	_, err := CreateNewBufferManager("./", uint64(1024), WithBackgroundFlush(-time.Second, 0))
+	if err == nil {
+		t.Fatal("negative interval should return an error but does not")
+	}
+	_, err = CreateNewBufferManager("./", uint64(1024), WithBackgroundFlush(0, -1))
+	if err == nil {
+		t.Fatal("negative threshold should return an error but does not")
+	}
+}
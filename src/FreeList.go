@@ -0,0 +1,348 @@
+package src
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/*
+ErrFreePage is returned when a page on the free list is read, it has to be allocated first
+*/
+var ErrFreePage = errors.New("the page is on the free list")
+
+/*
+AllocatePage hands out a page of the given file for a new node.
+Pages that have been given back with FreePage are reused first, the file only grows when the free list is empty.
+The returned page is empty on disk and not in the buffer. A file that does not exist yet is created.
+*/
+func (bm *BufferManager) AllocatePage(fileID string) (uint64, error) {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	if bm.readOnly {
+		return 0, ErrReadOnly
+	}
+	file, err := bm.file(fileID, true)
+	if err != nil {
+		return 0, err
+	}
+	pageInFile, err := file.Allocate()
+	if err != nil {
+		return 0, err
+	}
+	// a frame left from before the page has been freed would overwrite the new page
+	key := PageKey{File: fileID, Page: pageInFile}
+	if _, ok := bm.loads[key]; ok {
+		return 0, fmt.Errorf("page %d of %s has been allocated while it is being loaded", pageInFile, fileID)
+	}
+	if pageID, ok := bm.PageMap[key]; ok {
+		if bm.frames[pageID].pinCount > 0 {
+			return 0, fmt.Errorf("page %d of %s has been allocated while it is pinned", pageInFile, fileID)
+		}
+		bm.dropFrame(pageID)
+	}
+	return pageInFile, nil
+}
+
+/*
+FreePage gives a page of the given file back so that a later AllocatePage can reuse it.
+The page is dropped from the buffer without being written and cleared on disk.
+*/
+func (bm *BufferManager) FreePage(fileID string, pageInFile uint64) error {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	if bm.readOnly {
+		return ErrReadOnly
+	}
+	file, err := bm.file(fileID, false)
+	if err != nil {
+		return err
+	}
+	if pageInFile == file.Header().Root {
+		return errors.New("the root page cannot be freed")
+	}
+	count, err := file.PageCount()
+	if err != nil {
+		return err
+	}
+	if pageInFile >= count {
+		return fmt.Errorf("page %d is not part of %s", pageInFile, fileID)
+	}
+	freeList, err := file.FreePages()
+	if err != nil {
+		return err
+	}
+	for _, free := range freeList {
+		if free == pageInFile {
+			return fmt.Errorf("page %d of %s is already free", pageInFile, fileID)
+		}
+	}
+
+	if _, ok := bm.loads[PageKey{File: fileID, Page: pageInFile}]; ok {
+		return fmt.Errorf("page %d of %s is pinned and cannot be freed", pageInFile, fileID)
+	}
+	if pageID, ok := bm.PageMap[PageKey{File: fileID, Page: pageInFile}]; ok {
+		if bm.frames[pageID].pinCount > 0 {
+			return fmt.Errorf("page %d of %s is pinned and cannot be freed", pageInFile, fileID)
+		}
+		bm.dropFrame(pageID)
+	}
+	return file.Free(pageInFile)
+}
+
+/*
+CheckFreeList validates the persisted free list of the given file.
+Every entry has to be a page of the file other than the root, may only be listed once and has to be empty on disk.
+*/
+func (bm *BufferManager) CheckFreeList(fileID string) error {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	file, err := bm.file(fileID, false)
+	if err != nil {
+		return err
+	}
+	count, err := file.PageCount()
+	if err != nil {
+		return err
+	}
+	freeList, err := file.FreePages()
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[uint64]bool)
+	for _, pageInFile := range freeList {
+		if pageInFile == file.Header().Root {
+			return fmt.Errorf("free list of %s contains the root page", fileID)
+		}
+		if pageInFile >= count {
+			return fmt.Errorf("free list of %s contains page %d but the file only has %d pages", fileID, pageInFile, count)
+		}
+		if seen[pageInFile] {
+			return fmt.Errorf("free list of %s contains page %d more than once", fileID, pageInFile)
+		}
+		// binary files mark their free pages and ReadPage refuses them, the lines of a text file have to be empty
+		var page Page
+		if text, ok := bm.files[fileID].pageFile.(*textPageFile); ok {
+			text.mu.RLock()
+			page, err = text.readRow(pageInFile)
+			text.mu.RUnlock()
+		} else {
+			page, err = file.ReadPage(pageInFile)
+			if errors.Is(err, ErrFreePage) {
+				seen[pageInFile] = true
+				continue
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if page.Keys != [6]uint64{} || page.Values != [7]uint64{} {
+			return fmt.Errorf("free list of %s contains page %d which is still in use", fileID, pageInFile)
+		}
+		seen[pageInFile] = true
+	}
+	return nil
+}
+
+/*
+Allocate takes the head of the free list that is chained through the free pages or appends a page
+*/
+func (f *binaryPageFile) Allocate() (uint64, error) {
+	header := f.header
+	pageInFile := header.FreeHead
+	if pageInFile == noPage {
+		count, err := f.PageCount()
+		if err != nil {
+			return 0, err
+		}
+		pageInFile = count
+	} else {
+		next, err := f.nextFree(pageInFile)
+		if err != nil {
+			return 0, err
+		}
+		f.header.FreeHead = next
+	}
+
+	// the page leaves the free list in the same batch in which it is cleared
+	records, err := f.pageRecords([]Page{{Name: f.name, pageId: pageInFile}})
+	if err == nil {
+		err = f.writeRecords(append(records, f.headerRecord()))
+	}
+	if err != nil {
+		f.header = header
+		return 0, err
+	}
+	return pageInFile, nil
+}
+
+/*
+Free turns the page into the new head of the free list
+*/
+func (f *binaryPageFile) Free(pageInFile uint64) error {
+	header := f.header
+	buf := make([]byte, PageSize)
+	binary.LittleEndian.PutUint64(buf[freeNextOffset:], f.header.FreeHead)
+	buf[freeMarkOffset] = 1
+	setChecksum(buf)
+	f.header.FreeHead = pageInFile
+	err := f.writeRecords([]doubleWriteRecord{{offset: offset(pageInFile), buf: buf}, f.headerRecord()})
+	if err != nil {
+		f.header = header
+	}
+	return err
+}
+
+/*
+FreePages follows the free list from its head, a loop or a page outside of the file means the list is corrupt
+*/
+func (f *binaryPageFile) FreePages() ([]uint64, error) {
+	count, err := f.PageCount()
+	if err != nil {
+		return nil, err
+	}
+	var freeList []uint64
+	seen := make(map[uint64]bool)
+	for pageInFile := f.header.FreeHead; pageInFile != noPage; {
+		if pageInFile >= count {
+			return nil, fmt.Errorf("free list of %s points to page %d but the file only has %d pages", f.name, pageInFile, count)
+		}
+		if seen[pageInFile] {
+			return nil, fmt.Errorf("free list of %s contains a loop at page %d", f.name, pageInFile)
+		}
+		seen[pageInFile] = true
+		freeList = append(freeList, pageInFile)
+		pageInFile, err = f.nextFree(pageInFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return freeList, nil
+}
+
+/*
+nextFree returns the page that follows the given free page in the free list
+*/
+func (f *binaryPageFile) nextFree(pageInFile uint64) (uint64, error) {
+	buf, err := f.readRaw(pageInFile)
+	if err != nil {
+		return 0, err
+	}
+	if buf[freeMarkOffset] != 1 {
+		return 0, &CorruptionError{File: f.name, Page: pageInFile, Reason: "page is on the free list but not marked as free"}
+	}
+	return binary.LittleEndian.Uint64(buf[freeNextOffset:]), nil
+}
+
+/*
+Allocate reuses the most recently freed page of the free list file or appends an empty line
+*/
+func (f *textPageFile) Allocate() (uint64, error) {
+	freeList, err := f.FreePages()
+	if err != nil {
+		return 0, err
+	}
+
+	pageInFile, err := f.PageCount()
+	if err != nil {
+		return 0, err
+	}
+	if len(freeList) > 0 {
+		pageInFile = freeList[0]
+		freeList = freeList[1:]
+	}
+
+	// the page has to exist on disk before it leaves the free list, otherwise a crash in between loses it
+	err = f.WritePage(Page{Name: f.name, pageId: pageInFile})
+	if err != nil {
+		return 0, err
+	}
+	return pageInFile, f.writeFreeList(freeList)
+}
+
+/*
+Free clears the line of the page and adds it to the free list file
+*/
+func (f *textPageFile) Free(pageInFile uint64) error {
+	freeList, err := f.FreePages()
+	if err != nil {
+		return err
+	}
+	// freed pages are cleared so no stale entries can be read back
+	err = f.WritePage(Page{Name: f.name, pageId: pageInFile})
+	if err != nil {
+		return err
+	}
+	return f.writeFreeList(append([]uint64{pageInFile}, freeList...))
+}
+
+/*
+freeListName returns the name of the file the free list of a legacy text file is persisted in
+*/
+func freeListName(fileID string) string {
+	return fileID + ".free"
+}
+
+/*
+FreePages loads the free list file, it holds one page number per line with the most recently freed page last.
+A missing free list file means that no page has been freed yet.
+*/
+func (f *textPageFile) FreePages() ([]uint64, error) {
+	return readFreeListFile(f.storage, f.name)
+}
+
+/*
+parseFreeList reads the content of a free list file and returns the pages in the order they are reused
+*/
+func parseFreeList(fileID string, content string) ([]uint64, error) {
+	var freeList []uint64
+	for lineNr, line := range strings.Split(content, "\n") {
+		if line == "" {
+			continue
+		}
+		pageInFile, err := strconv.ParseUint(line, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("free list of %s is corrupt on line %d: %v", fileID, lineNr+1, err)
+		}
+		freeList = append([]uint64{pageInFile}, freeList...)
+	}
+	return freeList, nil
+}
+
+/*
+writeFreeList persists the free pages of the file
+*/
+func (f *textPageFile) writeFreeList(freeList []uint64) error {
+	err := writeFileAtomic(f.storage, freeListName(f.name), []byte(formatFreeList(freeList)), f.sync)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.setFree(freeList)
+	return nil
+}
+
+/*
+setFree remembers the pages of the free list for ReadPage
+*/
+func (f *textPageFile) setFree(freeList []uint64) {
+	f.free = make(map[uint64]bool, len(freeList))
+	for _, pageInFile := range freeList {
+		f.free[pageInFile] = true
+	}
+}
+
+/*
+formatFreeList returns the content of a free list file for the pages in the order they are reused
+*/
+func formatFreeList(freeList []uint64) string {
+	var outputString = ""
+	for i := len(freeList) - 1; i >= 0; i-- {
+		outputString = outputString + strconv.FormatUint(freeList[i], 10) + "\n"
+	}
+	return outputString
+}
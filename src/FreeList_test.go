@@ -0,0 +1,216 @@
+package src
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+/*
+TestFreeListAllocateGrowsFile tests that pages are appended to the file as long as nothing has been freed
+*/
+func TestFreeListAllocateGrowsFile(t *testing.T) {
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024))
+	defer func() {
+		_ = myBuffer.Delete("testFileForAllocate")
+	}()
+
+	for expected := uint64(0); expected < 3; expected++ {
+		pageInFile, err := myBuffer.AllocatePage("testFileForAllocate")
+		if err != nil {
+			t.Fatalf("error while allocating page: %v", err)
+		}
+		if pageInFile != expected {
+			t.Fatalf("AllocatePage returned page %d instead of %d", pageInFile, expected)
+		}
+	}
+
+	file, _ := myBuffer.openPageFile("testFileForAllocate", false)
+	count, _ := file.PageCount()
+	_ = file.Close()
+	if count != 3 {
+		t.Fatalf("file has %d pages instead of 3", count)
+	}
+}
+
+/*
+TestFreeListReuseAfterRestart tests that a freed page is reused by a new BufferManager before the file grows
+*/
+func TestFreeListReuseAfterRestart(t *testing.T) {
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024))
+	file, _ := os.Create("./testFileForFreeList")
+	_, _ = file.Write([]byte("10;;;;;;1;2;;;;;\n1;;;;;;2;;;;;;\n11;;;;;;12;;;;;;"))
+	_ = file.Close()
+	defer func() {
+		_ = myBuffer.Delete("testFileForFreeList")
+	}()
+
+	pageID, err := myBuffer.Pin("testFileForFreeList", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = myBuffer.FreePage("testFileForFreeList", 2)
+	if err == nil {
+		t.Fatal("freeing a pinned page should return an error but does not")
+	}
+	_ = myBuffer.Unpin(pageID, false)
+	err = myBuffer.FreePage("testFileForFreeList", 2)
+	if err != nil {
+		t.Fatalf("error while freeing page: %v", err)
+	}
+	if _, ok := myBuffer.PageMap[PageKey{File: "testFileForFreeList", Page: 2}]; ok {
+		t.Fatal("freed page is still mapped in the buffer")
+	}
+	err = myBuffer.CheckFreeList("testFileForFreeList")
+	if err != nil {
+		t.Fatalf("free list is invalid after freeing a page: %v", err)
+	}
+
+	_ = myBuffer.Close()
+	restarted, _ := CreateNewBufferManager("./", uint64(1024))
+	defer func() {
+		_ = restarted.Close()
+	}()
+	pageInFile, err := restarted.AllocatePage("testFileForFreeList")
+	if err != nil {
+		t.Fatalf("error while allocating page: %v", err)
+	}
+	if pageInFile != 2 {
+		t.Fatalf("AllocatePage returned page %d instead of the freed page 2", pageInFile)
+	}
+	pageInFile, _ = restarted.AllocatePage("testFileForFreeList")
+	if pageInFile != 3 {
+		t.Fatalf("AllocatePage returned page %d instead of growing the file to page 3", pageInFile)
+	}
+}
+
+/*
+TestFreeListFreeWithError tests that the root, unknown pages and already free pages cannot be freed
+*/
+func TestFreeListFreeWithError(t *testing.T) {
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024))
+	defer func() {
+		_ = myBuffer.Delete("testFileForFreeWithError")
+	}()
+	_, _ = myBuffer.AllocatePage("testFileForFreeWithError")
+	_, _ = myBuffer.AllocatePage("testFileForFreeWithError")
+
+	if err := myBuffer.FreePage("testFileForFreeWithError", 0); err == nil {
+		t.Error("freeing the root page should return an error but does not")
+	}
+	if err := myBuffer.FreePage("testFileForFreeWithError", 5); err == nil {
+		t.Error("freeing a page outside of the file should return an error but does not")
+	}
+	if err := myBuffer.FreePage("testFileForFreeWithError", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := myBuffer.FreePage("testFileForFreeWithError", 1); err == nil {
+		t.Error("freeing a page twice should return an error but does not")
+	}
+}
+
+/*
+TestFreeListCheckWithError tests that a damaged free list is detected
+*/
+func TestFreeListCheckWithError(t *testing.T) {
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024))
+	file, _ := os.Create("./testFileForCheckFreeList")
+	_, _ = file.Write([]byte("10;;;;;;1;2;;;;;\n1;;;;;;2;;;;;;"))
+	_ = file.Close()
+	defer func() {
+		_ = myBuffer.Delete("testFileForCheckFreeList")
+	}()
+
+	for _, content := range []string{"1\n", "7\n", "x\n"} {
+		_ = os.WriteFile("./"+freeListName("testFileForCheckFreeList"), []byte(content), 0644)
+		if err := myBuffer.CheckFreeList("testFileForCheckFreeList"); err == nil {
+			t.Errorf("CheckFreeList accepted the free list %q", content)
+		}
+	}
+}
+
+/*
+TestFreeListPinFreedTextPage tests that a page on the free list of a text file cannot be pinned and is empty after it has been allocated again
+*/
+func TestFreeListPinFreedTextPage(t *testing.T) {
+	storage := NewMemoryStorage()
+	file, _ := storage.Open("testFileForPinFreedText", true)
+	_, _ = file.WriteAt([]byte("10;;;;;;1;2;;;;;\n1;;;;;;2;;;;;;\n11;;;;;;12;;;;;;\n"), 0)
+	_ = file.Close()
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024), WithStorage(storage))
+	defer func() {
+		_ = myBuffer.Close()
+	}()
+
+	pageID, _ := myBuffer.Pin("testFileForPinFreedText", 2)
+	myBuffer.Pages[pageID].Keys[0] = 99
+	_ = myBuffer.Unpin(pageID, true)
+	err := myBuffer.FreePage("testFileForPinFreedText", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = myBuffer.Pin("testFileForPinFreedText", 2)
+	if !errors.Is(err, ErrFreePage) {
+		t.Fatalf("pinning a free page of a text file returned %v", err)
+	}
+
+	pageInFile, err := myBuffer.AllocatePage("testFileForPinFreedText")
+	if err != nil || pageInFile != 2 {
+		t.Fatalf("AllocatePage returned %d, %v instead of the free page 2", pageInFile, err)
+	}
+	pageID, err = myBuffer.Pin("testFileForPinFreedText", 2)
+	if err != nil {
+		t.Fatalf("the allocated page cannot be pinned: %v", err)
+	}
+	if myBuffer.Pages[pageID].Keys != [6]uint64{} || myBuffer.Pages[pageID].Values != [7]uint64{} {
+		t.Errorf("the allocated page has keys %v and values %v", myBuffer.Pages[pageID].Keys, myBuffer.Pages[pageID].Values)
+	}
+	_ = myBuffer.Unpin(pageID, false)
+	err = myBuffer.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ := readStorageFile(storage, "testFileForPinFreedText")
+	if string(content) != "10;;;;;;1;2;;;;;\n1;;;;;;2;;;;;;\n;;;;;;;;;;;;" {
+		t.Errorf("the text file contains %q", content)
+	}
+}
+
+/*
+TestFreeListPinFreedPage tests that a page on the free list cannot be pinned and written back until it is allocated again
+*/
+func TestFreeListPinFreedPage(t *testing.T) {
+	storage := NewMemoryStorage()
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024), WithStorage(storage))
+	defer func() {
+		_ = myBuffer.Close()
+	}()
+	for i := 0; i < 3; i++ {
+		if _, err := myBuffer.AllocatePage("testFileForPinFreed"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := myBuffer.FreePage("testFileForPinFreed", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = myBuffer.Pin("testFileForPinFreed", 2)
+	if !errors.Is(err, ErrFreePage) {
+		t.Fatalf("pinning a free page returned %v", err)
+	}
+	err = myBuffer.CheckFreeList("testFileForPinFreed")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pageInFile, err := myBuffer.AllocatePage("testFileForPinFreed")
+	if err != nil || pageInFile != 2 {
+		t.Fatalf("AllocatePage returned %d, %v instead of the free page 2", pageInFile, err)
+	}
+	pageID, err := myBuffer.Pin("testFileForPinFreed", 2)
+	if err != nil {
+		t.Fatalf("the allocated page cannot be pinned: %v", err)
+	}
+	_ = myBuffer.Unpin(pageID, false)
+}
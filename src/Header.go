@@ -0,0 +1,171 @@
+package src
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+const (
+	formatVersion = 1              // version of the binary format written by this package
+	nodeOrder     = 7              // maximum number of children of a node, a node has one key less
+	noPage        = math.MaxUint64 // marks the end of the free list
+)
+
+var fileMagic = [8]byte{'D', 'M', 'D', 'S', 'T', 'R', 'E', 'E'}
+
+/*
+The header takes up the first PageSize bytes of a binary tree file, the pages follow it.
+It is protected by a checksum at the same place as in a page.
+*/
+const (
+	headerVersionOffset   = 8
+	headerPageSizeOffset  = 12
+	headerOrderOffset     = 16
+	headerRootOffset      = 24
+	headerHeightOffset    = 32
+	headerFreeHeadOffset  = 40
+	headerFlagsOffset     = 48
+	headerTableOffset     = 56 // place of the slot table of a compressed file
+	headerTableSizeOffset = 64
+	headerSaltOffset      = 72 // parameters of the encryption of an encrypted file
+	headerKeyCheckOffset  = 88
+	headerLeaseOffset     = 104
+)
+
+/*
+Flags of the header that describe how the pages are stored
+*/
+const (
+	flagCompressed = 1 // the pages are compressed, see Compression.go
+	flagEncrypted  = 2 // the pages are encrypted, see Encryption.go
+)
+
+/*
+FileHeader describes the layout and the root of a tree file.
+Legacy text files have no header, they are described by Version 0 and the root page 0 with one level of leaves below.
+*/
+type FileHeader struct {
+	Version    uint32 // format version, 0 for legacy text files
+	PageSize   uint32 // size of one page in bytes
+	Order      uint32 // maximum number of children of a node
+	Root       uint64 // page of the root node
+	Height     uint64 // number of levels below the root, 0 if the root is a leaf
+	FreeHead   uint64 // first page of the free list
+	Compressed bool   // the pages are stored compressed
+	Encrypted  bool   // the pages are stored encrypted
+}
+
+/*
+Header returns the header of the given tree file
+*/
+func (bm *BufferManager) Header(fileID string) (FileHeader, error) {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	file, err := bm.file(fileID, false)
+	if err != nil {
+		return FileHeader{}, err
+	}
+	return file.Header(), nil
+}
+
+/*
+SetRoot stores a new root page and height in the header of the given tree file, e.g. after the root has been split.
+Legacy text files always have their root in page 0 and cannot be changed.
+*/
+func (bm *BufferManager) SetRoot(fileID string, root uint64, height uint64) error {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	if bm.readOnly {
+		return ErrReadOnly
+	}
+	file, err := bm.file(fileID, false)
+	if err != nil {
+		return err
+	}
+	count, err := file.PageCount()
+	if err != nil {
+		return err
+	}
+	if root >= count {
+		return fmt.Errorf("page %d cannot become the root, %s only has %d pages", root, fileID, count)
+	}
+	return file.SetRoot(root, height)
+}
+
+/*
+newHeader returns the header of an empty tree file
+*/
+func newHeader() FileHeader {
+	return FileHeader{Version: formatVersion, PageSize: PageSize, Order: nodeOrder, Root: 0, Height: 0, FreeHead: noPage}
+}
+
+/*
+legacyHeader returns the header that describes a legacy text file
+*/
+func legacyHeader() FileHeader {
+	return FileHeader{Version: 0, PageSize: 0, Order: nodeOrder, Root: 0, Height: 1, FreeHead: noPage}
+}
+
+/*
+encodeHeader writes the header and its checksum into buf which has to be PageSize bytes long
+*/
+func encodeHeader(header FileHeader, buf []byte) {
+	copy(buf, fileMagic[:])
+	binary.LittleEndian.PutUint32(buf[headerVersionOffset:], header.Version)
+	binary.LittleEndian.PutUint32(buf[headerPageSizeOffset:], header.PageSize)
+	binary.LittleEndian.PutUint32(buf[headerOrderOffset:], header.Order)
+	binary.LittleEndian.PutUint64(buf[headerRootOffset:], header.Root)
+	binary.LittleEndian.PutUint64(buf[headerHeightOffset:], header.Height)
+	binary.LittleEndian.PutUint64(buf[headerFreeHeadOffset:], header.FreeHead)
+	flags := uint32(0)
+	if header.Compressed {
+		flags |= flagCompressed
+	}
+	if header.Encrypted {
+		flags |= flagEncrypted
+	}
+	binary.LittleEndian.PutUint32(buf[headerFlagsOffset:], flags)
+	setChecksum(buf)
+}
+
+/*
+decodeHeader reads the header out of buf and rejects files this package does not understand
+*/
+func decodeHeader(fileID string, buf []byte) (FileHeader, error) {
+	if !bytes.HasPrefix(buf, fileMagic[:]) {
+		return FileHeader{}, fmt.Errorf("%s is not a tree file, the magic number is missing", fileID)
+	}
+	if len(buf) < PageSize {
+		return FileHeader{}, &CorruptionError{File: fileID, Page: noPage, Reason: "the header is truncated"}
+	}
+	if !validChecksum(buf) {
+		return FileHeader{}, &CorruptionError{File: fileID, Page: noPage, Reason: "checksum mismatch"}
+	}
+
+	header := FileHeader{
+		Version:  binary.LittleEndian.Uint32(buf[headerVersionOffset:]),
+		PageSize: binary.LittleEndian.Uint32(buf[headerPageSizeOffset:]),
+		Order:    binary.LittleEndian.Uint32(buf[headerOrderOffset:]),
+		Root:     binary.LittleEndian.Uint64(buf[headerRootOffset:]),
+		Height:   binary.LittleEndian.Uint64(buf[headerHeightOffset:]),
+		FreeHead: binary.LittleEndian.Uint64(buf[headerFreeHeadOffset:]),
+	}
+	flags := binary.LittleEndian.Uint32(buf[headerFlagsOffset:])
+	if flags&^(flagCompressed|flagEncrypted) != 0 {
+		return FileHeader{}, fmt.Errorf("%s has the unknown flags %#x", fileID, flags&^(flagCompressed|flagEncrypted))
+	}
+	header.Compressed = flags&flagCompressed != 0
+	header.Encrypted = flags&flagEncrypted != 0
+	if header.Version != formatVersion {
+		return FileHeader{}, fmt.Errorf("%s has format version %d, only version %d is supported", fileID, header.Version, formatVersion)
+	}
+	if header.PageSize != PageSize {
+		return FileHeader{}, fmt.Errorf("%s has pages of %d bytes, only %d bytes are supported", fileID, header.PageSize, PageSize)
+	}
+	if header.Order != nodeOrder {
+		return FileHeader{}, fmt.Errorf("%s has nodes of order %d, only order %d is supported", fileID, header.Order, nodeOrder)
+	}
+	return header, nil
+}
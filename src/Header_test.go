@@ -0,0 +1,164 @@
+package src
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+/*
+TestHeaderRejectsUnknownFiles tests that files with a wrong magic number or version are rejected with a clear error
+*/
+func TestHeaderRejectsUnknownFiles(t *testing.T) {
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024))
+	var myLoader = Loader{}
+	defer func() {
+		_ = os.Remove("./testFileForHeader")
+	}()
+
+	buf := make([]byte, PageSize)
+	header := newHeader()
+	header.Version = formatVersion + 1
+	encodeHeader(header, buf)
+	_ = os.WriteFile("./testFileForHeader", buf, 0644)
+	_, err := myLoader.Load("testFileForHeader", myBuffer)
+	if err == nil || !strings.Contains(err.Error(), "format version") {
+		t.Fatalf("expected an error about the format version but got %v", err)
+	}
+
+	buf[0] = 0
+	_ = os.WriteFile("./testFileForHeader", buf, 0644)
+	_, err = myLoader.Load("testFileForHeader", myBuffer)
+	if err == nil || !strings.Contains(err.Error(), "unknown format") {
+		t.Fatalf("expected an error about the unknown format but got %v", err)
+	}
+
+	encodeHeader(newHeader(), buf)
+	buf[headerRootOffset] ^= 0x01
+	_ = os.WriteFile("./testFileForHeader", buf, 0644)
+	_, err = myLoader.Load("testFileForHeader", myBuffer)
+	var corruptionError *CorruptionError
+	if !errors.As(err, &corruptionError) || corruptionError.Page != noPage {
+		t.Fatalf("expected a CorruptionError for the header but got %v", err)
+	}
+}
+
+/*
+TestHeaderRootSurvivesRestart tests that a root moved with SetRoot is used by the Loader of a new BufferManager
+*/
+func TestHeaderRootSurvivesRestart(t *testing.T) {
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024))
+	defer func() {
+		_ = os.Remove("./testFileForRoot")
+	}()
+	for i := 0; i < 3; i++ {
+		_, _ = myBuffer.AllocatePage("testFileForRoot")
+	}
+
+	err := myBuffer.SetRoot("testFileForRoot", 3, 1)
+	if err == nil {
+		t.Fatal("setting a root outside of the file should return an error but does not")
+	}
+	err = myBuffer.SetRoot("testFileForRoot", 2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = myBuffer.FreePage("testFileForRoot", 2)
+	if err == nil {
+		t.Fatal("freeing the root should return an error but does not")
+	}
+	_ = myBuffer.Close()
+
+	var otherBuffer, _ = CreateNewBufferManager("./", uint64(1024))
+	var myLoader = Loader{}
+	tree, err := myLoader.Load("testFileForRoot", otherBuffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree.RootPageId != 2 || tree.Height != 1 {
+		t.Fatalf("tree has root %d with height %d instead of root 2 with height 1", tree.RootPageId, tree.Height)
+	}
+	_ = otherBuffer.Close()
+}
+
+/*
+TestHeaderLegacyTextFile tests that a text file without header opens with its root in page 0
+*/
+func TestHeaderLegacyTextFile(t *testing.T) {
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024))
+	file, _ := os.Create("./testFileForLegacy")
+	_, _ = file.Write([]byte("10;;;;;;1;2;;;;;\n1;;;;;;2;;;;;;\n11;;;;;;12;;;;;;"))
+	_ = file.Close()
+	defer func() {
+		_ = myBuffer.Delete("testFileForLegacy")
+	}()
+
+	header, err := myBuffer.Header("testFileForLegacy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header != legacyHeader() {
+		t.Fatalf("legacy file has header %+v instead of %+v", header, legacyHeader())
+	}
+	if myBuffer.SetRoot("testFileForLegacy", 1, 1) == nil {
+		t.Fatal("moving the root of a legacy file should return an error but does not")
+	}
+	var myLoader = Loader{}
+	tree, err := myLoader.Load("testFileForLegacy", myBuffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err := tree.Get(11)
+	if err != nil || value != 12 {
+		t.Fatalf("Get returned %d, %v instead of 12", value, err)
+	}
+}
+
+/*
+TestHeaderFreeListChain tests that the free list chained through the pages of a binary file survives a restart
+and that a broken chain is detected
+*/
+func TestHeaderFreeListChain(t *testing.T) {
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024))
+	defer func() {
+		_ = os.Remove("./testFileForChain")
+	}()
+	for i := 0; i < 4; i++ {
+		_, _ = myBuffer.AllocatePage("testFileForChain")
+	}
+	_ = myBuffer.FreePage("testFileForChain", 1)
+	_ = myBuffer.FreePage("testFileForChain", 3)
+	_ = myBuffer.Close()
+
+	var otherBuffer, _ = CreateNewBufferManager("./", uint64(1024))
+	err := otherBuffer.CheckFreeList("testFileForChain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []uint64{3, 1, 4} {
+		pageInFile, err := otherBuffer.AllocatePage("testFileForChain")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pageInFile != expected {
+			t.Fatalf("AllocatePage returned page %d instead of %d", pageInFile, expected)
+		}
+	}
+	_ = otherBuffer.FreePage("testFileForChain", 2)
+	_ = otherBuffer.FreePage("testFileForChain", 1)
+	_ = otherBuffer.Close()
+
+	// let page 2 point back to page 1
+	dat, _ := os.ReadFile("./testFileForChain")
+	buf := dat[3*PageSize : 4*PageSize]
+	buf[freeNextOffset] = 1
+	setChecksum(buf)
+	_ = os.WriteFile("./testFileForChain", dat, 0644)
+
+	var lastBuffer, _ = CreateNewBufferManager("./", uint64(1024))
+	if lastBuffer.CheckFreeList("testFileForChain") == nil {
+		t.Fatal("free list with a loop should return an error but does not")
+	}
+	_ = lastBuffer.Close()
+}
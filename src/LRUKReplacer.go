@@ -0,0 +1,68 @@
+package src
+
+/*
+lruKReplacer evicts the evictable frame with the largest backward k-distance, the time since its k-th last access.
+Frames with less than k accesses have an infinite distance and are evicted first, oldest access first.
+A page that is only touched once, e.g. by a scan, therefore cannot push out pages that are used repeatedly.
+*/
+type lruKReplacer struct {
+	k         int
+	now       uint64
+	history   map[uint64][]uint64 // the last k access times of each tracked frame, oldest first
+	evictable map[uint64]bool
+}
+
+func newLRUKReplacer(k int) *lruKReplacer {
+	return &lruKReplacer{k: k, history: make(map[uint64][]uint64), evictable: make(map[uint64]bool)}
+}
+
+func (r *lruKReplacer) RecordAccess(frameID uint64, page PageKey) {
+	r.now++
+	accesses := append(r.history[frameID], r.now)
+	if len(accesses) > r.k {
+		accesses = accesses[1:]
+	}
+	r.history[frameID] = accesses
+}
+
+func (r *lruKReplacer) SetEvictable(frameID uint64, evictable bool) {
+	if _, ok := r.history[frameID]; ok {
+		r.evictable[frameID] = evictable
+	}
+}
+
+func (r *lruKReplacer) Evict() (uint64, bool) {
+	found := false
+	var victim uint64
+	for frameID, accesses := range r.history {
+		if !r.evictable[frameID] {
+			continue
+		}
+		if !found || r.evictsBefore(accesses, r.history[victim]) {
+			victim = frameID
+			found = true
+		}
+	}
+	if found {
+		r.Remove(victim)
+	}
+	return victim, found
+}
+
+/*
+evictsBefore reports if a frame with the access history a is a better victim than one with the history b
+*/
+func (r *lruKReplacer) evictsBefore(a []uint64, b []uint64) bool {
+	aInfinite := len(a) < r.k
+	bInfinite := len(b) < r.k
+	if aInfinite != bInfinite {
+		return aInfinite
+	}
+	// either both distances are infinite and the oldest access decides or the oldest of the k accesses is the k-th last one
+	return a[0] < b[0]
+}
+
+func (r *lruKReplacer) Remove(frameID uint64) {
+	delete(r.history, frameID)
+	delete(r.evictable, frameID)
+}
@@ -0,0 +1,48 @@
+package src
+
+import "container/list"
+
+/*
+lruReplacer evicts the evictable frame that has not been pinned for the longest time
+*/
+type lruReplacer struct {
+	order     *list.List               // frame ids, the most recently used one at the front
+	elements  map[uint64]*list.Element // position of each tracked frame in order
+	evictable map[uint64]bool
+}
+
+func newLRUReplacer() *lruReplacer {
+	return &lruReplacer{order: list.New(), elements: make(map[uint64]*list.Element), evictable: make(map[uint64]bool)}
+}
+
+func (r *lruReplacer) RecordAccess(frameID uint64, page PageKey) {
+	if e, ok := r.elements[frameID]; ok {
+		r.order.MoveToFront(e)
+		return
+	}
+	r.elements[frameID] = r.order.PushFront(frameID)
+}
+
+func (r *lruReplacer) SetEvictable(frameID uint64, evictable bool) {
+	if _, ok := r.elements[frameID]; ok {
+		r.evictable[frameID] = evictable
+	}
+}
+
+func (r *lruReplacer) Evict() (uint64, bool) {
+	e := lastEvictable(r.order, r.evictable)
+	if e == nil {
+		return 0, false
+	}
+	frameID := e.Value.(uint64)
+	r.Remove(frameID)
+	return frameID, true
+}
+
+func (r *lruReplacer) Remove(frameID uint64) {
+	if e, ok := r.elements[frameID]; ok {
+		r.order.Remove(e)
+		delete(r.elements, frameID)
+		delete(r.evictable, frameID)
+	}
+}
@@ -0,0 +1,126 @@
+package src
+
+import (
+	"errors"
+	"sync"
+)
+
+/*
+A BufferManager is safe for concurrent use. The page table, the frames and the open files are guarded by
+the latch of the page table, it is only held for the bookkeeping. Disk reads of pages that are not in the
+buffer run without it. The content of a page is guarded by the latch of its frame, a pinned page is read
+and modified through Page and SetPage. Latches are always taken in this order: page table before frame.
+*/
+
+/*
+pageLoad is a disk read of a page in progress, pins of the same page wait for it instead of reading it again
+*/
+type pageLoad struct {
+	done chan struct{} // closed when the read is finished
+	err  error         // error of the read, only valid once done is closed
+}
+
+/*
+newLatches creates the latches for count frames
+*/
+func newLatches(count int) []*sync.RWMutex {
+	latches := make([]*sync.RWMutex, count)
+	for i := range latches {
+		latches[i] = &sync.RWMutex{}
+	}
+	return latches
+}
+
+/*
+load reads the page of key from disk into a free frame or a frame of the ring. The frame is reserved for the page and pinned,
+so it is neither evicted nor handed out while the latch of the page table is released during the read.
+The latch of the page table has to be held when load is called, it is held again when it returns.
+*/
+func (bm *BufferManager) load(key PageKey, ring *ScanRing) (uint64, error) {
+	var pageID uint64
+	var err error
+	if ring != nil {
+		pageID, err = ring.frame()
+	} else {
+		pageID, err = bm.freeFrame()
+	}
+	if err != nil {
+		return 0, err
+	}
+	_, err = bm.file(key.File, false)
+	if err != nil {
+		return 0, err
+	}
+	handle := bm.files[key.File]
+	handle.users++
+	bm.frames[pageID] = frame{used: true, pinCount: 1, loading: true}
+	load := &pageLoad{done: make(chan struct{})}
+	bm.loads[key] = load
+
+	bm.mu.Unlock()
+	page, err := handle.ReadPage(key.Page)
+	bm.mu.Lock()
+
+	handle.users--
+	delete(bm.loads, key)
+	load.err = err
+	close(load.done)
+	if err != nil {
+		bm.frames[pageID] = frame{}
+		bm.signalFrameFreed()
+		return 0, err
+	}
+	bm.Pages[pageID] = page
+	bm.frames[pageID].loading = false
+	bm.PageMap[key] = pageID
+	bm.replacer.RecordAccess(pageID, key)
+	bm.replacer.SetEvictable(pageID, false)
+	if ring != nil {
+		ring.record(pageID, key)
+	}
+	return pageID, nil
+}
+
+/*
+latch returns the latch of the frame, the page in it has to be pinned
+*/
+func (bm *BufferManager) latch(pageID uint64) (*sync.RWMutex, error) {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	if pageID >= uint64(len(bm.frames)) || !bm.frames[pageID].used || bm.frames[pageID].loading || bm.frames[pageID].pinCount == 0 {
+		return nil, errors.New("the page at this Id is not pinned")
+	}
+	return bm.latches[pageID], nil
+}
+
+/*
+Page returns a copy of the pinned page in the given frame
+*/
+func (bm *BufferManager) Page(pageID uint64) (Page, error) {
+	latch, err := bm.latch(pageID)
+	if err != nil {
+		return Page{}, err
+	}
+	latch.RLock()
+	defer latch.RUnlock()
+	return bm.Pages[pageID], nil
+}
+
+/*
+SetPage replaces the keys and values of the pinned page in the given frame.
+The page still has to be unpinned as dirty to be written back.
+*/
+func (bm *BufferManager) SetPage(pageID uint64, page Page) error {
+	if bm.readOnly {
+		return ErrReadOnly
+	}
+	latch, err := bm.latch(pageID)
+	if err != nil {
+		return err
+	}
+	latch.Lock()
+	defer latch.Unlock()
+	bm.Pages[pageID].Keys = page.Keys
+	bm.Pages[pageID].Values = page.Values
+	return nil
+}
@@ -0,0 +1,167 @@
+package src
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+/*
+gateStorage blocks every read at the offset until release is closed and counts them
+*/
+type gateStorage struct {
+	Storage
+	offset  int64
+	reads   int32
+	release chan struct{}
+}
+
+func (s *gateStorage) Open(name string, create bool) (StorageFile, error) {
+	file, err := s.Storage.Open(name, create)
+	if err != nil {
+		return nil, err
+	}
+	return &gateFile{StorageFile: file, storage: s}, nil
+}
+
+type gateFile struct {
+	StorageFile
+	storage *gateStorage
+}
+
+func (f *gateFile) ReadAt(p []byte, off int64) (int, error) {
+	if off == f.storage.offset {
+		atomic.AddInt32(&f.storage.reads, 1)
+		<-f.storage.release
+	}
+	return f.StorageFile.ReadAt(p, off)
+}
+
+/*
+TestLatchSingleFlight tests that concurrent pins of a page that is not in the buffer read it only once
+*/
+func TestLatchSingleFlight(t *testing.T) {
+	storage := &gateStorage{Storage: NewMemoryStorage(), offset: offset(1), release: make(chan struct{})}
+	createTreeInStorage(t, storage, "testFileForSingleFlight")
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024), WithStorage(storage))
+	_ = myBuffer.Open("testFileForSingleFlight")
+
+	var wg sync.WaitGroup
+	pageIDs := make([]uint64, 8)
+	for i := range pageIDs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			pageID, err := myBuffer.Pin("testFileForSingleFlight", 1)
+			if err != nil {
+				t.Error(err)
+			}
+			pageIDs[i] = pageID
+		}(i)
+	}
+	waitFor(t, func() bool { return atomic.LoadInt32(&storage.reads) == 1 })
+	// the other pins must not be blocked by the latch of the page table while the page is read
+	if myBuffer.HitRatio() != 0 {
+		t.Fatal("page has been found in the buffer before it has been read")
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(storage.release)
+	wg.Wait()
+
+	if reads := atomic.LoadInt32(&storage.reads); reads != 1 {
+		t.Fatalf("page has been read %d times instead of once", reads)
+	}
+	for _, pageID := range pageIDs {
+		if pageID != pageIDs[0] {
+			t.Fatalf("pins returned the frames %v instead of the same frame", pageIDs)
+		}
+	}
+	if myBuffer.frames[pageIDs[0]].pinCount != len(pageIDs) {
+		t.Fatalf("frame has %d pins instead of %d", myBuffer.frames[pageIDs[0]].pinCount, len(pageIDs))
+	}
+}
+
+/*
+TestLatchFailedLoadFreesFrame tests that a frame reserved for a page that cannot be read is given back
+*/
+func TestLatchFailedLoadFreesFrame(t *testing.T) {
+	storage := NewMemoryStorage()
+	createTreeInStorage(t, storage, "testFileForFailedLoad")
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(PageSize), WithStorage(storage))
+
+	_, err := myBuffer.Pin("testFileForFailedLoad", 7)
+	if err == nil {
+		t.Fatal("pin of a page outside of the file should return an error but does not")
+	}
+	pageID, err := myBuffer.Pin("testFileForFailedLoad", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = myBuffer.Page(pageID + 1)
+	if err == nil {
+		t.Fatal("access to a frame that is not pinned should return an error but does not")
+	}
+	_ = myBuffer.Unpin(pageID, false)
+	if _, err = myBuffer.Page(pageID); err == nil {
+		t.Fatal("access to an unpinned page should return an error but does not")
+	}
+}
+
+/*
+TestLatchConcurrentUse tests that many goroutines can pin, modify and unpin pages of several files at the same time
+*/
+func TestLatchConcurrentUse(t *testing.T) {
+	storage := NewMemoryStorage()
+	files := []string{"testFileForConcurrency1", "testFileForConcurrency2"}
+	for _, fileID := range files {
+		createTreeInStorage(t, storage, fileID)
+	}
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(4*PageSize), WithStorage(storage), WithBackgroundFlush(time.Millisecond, 2))
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				fileID := files[(g+i)%len(files)]
+				pageID, err := myBuffer.Pin(fileID, uint64(i%3))
+				if err != nil {
+					// transient exhaustion of the small pool
+					continue
+				}
+				page, err := myBuffer.Page(pageID)
+				if err != nil {
+					t.Error(err)
+				}
+				page.Values[6] = uint64(g)
+				err = myBuffer.SetPage(pageID, page)
+				if err != nil {
+					t.Error(err)
+				}
+				if i%50 == 0 {
+					_ = myBuffer.Resize(uint64((4 + i%2) * PageSize))
+				}
+				err = myBuffer.Unpin(pageID, true)
+				if err != nil {
+					t.Error(err)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	err := myBuffer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for key, pageID := range myBuffer.PageMap {
+		if myBuffer.frames[pageID].pinCount != 0 || myBuffer.frames[pageID].dirty {
+			t.Fatalf("frame %d is still pinned or dirty", pageID)
+		}
+		if myBuffer.Pages[pageID].key() != key {
+			t.Fatalf("page table maps %v to the frame of %v", key, myBuffer.Pages[pageID].key())
+		}
+	}
+}
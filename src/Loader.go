@@ -4,12 +4,37 @@ type Loader struct{}
 
 /*
 Load loads the initial root node of a BTree and returns it.
-Each page has a seperate file with an id as the name
+The root and the height of the tree are taken from the header of the file, files that cannot be understood are rejected.
+Legacy text files without a header have their root in page 0.
 */
 func (l *Loader) Load(name string, manager *BufferManager) (*BTree, error) {
-	id, err := manager.Pin(name, 0)
+	header, err := manager.Header(name)
 	if err != nil {
 		return nil, err
 	}
-	return &BTree{Name: name, RootPageId: id, Manager: manager}, nil
+	// the root is looked up by its page in the file, the frame may be shared with other trees
+	err = checkRoot(manager, name, header.Root)
+	if err != nil {
+		return nil, err
+	}
+	return &BTree{Name: name, RootPageId: header.Root, Height: int(header.Height), Manager: manager}, nil
+}
+
+/*
+checkRoot reads the root once so that a tree that cannot be read is rejected by Load.
+A mapped file is read through a view, see WithMmap.
+*/
+func checkRoot(manager *BufferManager, name string, root uint64) error {
+	if manager.isMapped(name) {
+		view, err := manager.PinView(name, root)
+		if err != nil {
+			return err
+		}
+		return view.Release()
+	}
+	handle, err := manager.PinHandle(name, root)
+	if err != nil {
+		return err
+	}
+	return handle.Release()
 }
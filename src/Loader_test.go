@@ -1,6 +1,7 @@
 package src
 
 import (
+	"errors"
 	"os"
 	"reflect"
 	"testing"
@@ -12,18 +13,21 @@ TestLoader tests if the Loader returns the correct page
 func TestLoaderSetup(t *testing.T) {
 	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024))
 	file, _ := os.Create("./testFileForLoader")
-	_, _ = file.Write([]byte("1;2;3;4;5;6;a;b;c;d;e;f;g"))
+	_, _ = file.Write([]byte("1;2;3;4;5;6;7;8;9;10;11;12;13"))
 	_ = file.Close()
 	loader := Loader{}
 
 	defer func() {
 		_ = os.Remove("./testFileForLoader")
 	}()
+	defer func() {
+		_ = myBuffer.Close()
+	}()
 	tree, err := loader.Load("./testFileForLoader", myBuffer)
 	if err != nil {
 		t.Errorf("Error loading file: %v", err)
 	}
-	expectedBTree := &BTree{Name: "./testFileForLoader", RootPageId: 0, Manager: myBuffer}
+	expectedBTree := &BTree{Name: "./testFileForLoader", RootPageId: 0, Height: 1, Manager: myBuffer}
 	if !reflect.DeepEqual(tree, expectedBTree) {
 		t.Errorf("loaded tree does not match expected tree")
 	}
@@ -42,9 +46,39 @@ func TestLoaderSetupWithError(t *testing.T) {
 	defer func() {
 		_ = os.Remove("./testFileForLoaderWithError")
 	}()
+	defer func() {
+		_ = myBuffer.Close()
+	}()
 
 	_, err := loader.Load("./testFileForLoaderWithError", myBuffer)
 	if err == nil {
 		t.Errorf("No error thrown loading file but should have")
 	}
 }
+
+/*
+TestLoaderSetupWithCorruption tests that a root page with fields that are no numbers is not loaded as zeros
+*/
+func TestLoaderSetupWithCorruption(t *testing.T) {
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024))
+	file, _ := os.Create("./testFileForLoaderWithCorruption")
+	_, _ = file.Write([]byte("1;2;3;4;5;6;a;b;c;d;e;f;g"))
+	_ = file.Close()
+	loader := Loader{}
+
+	defer func() {
+		_ = os.Remove("./testFileForLoaderWithCorruption")
+	}()
+	defer func() {
+		_ = myBuffer.Close()
+	}()
+
+	_, err := loader.Load("testFileForLoaderWithCorruption", myBuffer)
+	var corruptionError *CorruptionError
+	if !errors.As(err, &corruptionError) {
+		t.Fatalf("expected a CorruptionError but got %v", err)
+	}
+	if corruptionError.File != "testFileForLoaderWithCorruption" || corruptionError.Page != 0 {
+		t.Errorf("error names page %d of %s", corruptionError.Page, corruptionError.File)
+	}
+}
@@ -0,0 +1,111 @@
+package src
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+/*
+A BufferManager locks every tree file it opens until it is closed, so two processes never write the same tree.
+Closing the handle of a file, also when it is evicted from the table of open files, keeps the lock, only Delete releases it earlier.
+Read-write BufferManagers take an exclusive lock, read-only BufferManagers a shared one, see WithReadOnly.
+The locks are taken on a lock file next to the tree file because text files are replaced on every write.
+They are only taken if the Storage implements Locker.
+*/
+
+/*
+ErrLocked is returned when a tree file is locked by another BufferManager, usually in another process
+*/
+var ErrLocked = errors.New("the file is locked")
+
+/*
+ErrReadOnly is returned for changes through a read-only BufferManager
+*/
+var ErrReadOnly = errors.New("the buffer manager is read-only")
+
+/*
+Locker is implemented by a Storage whose files can be locked against other processes
+*/
+type Locker interface {
+	// Lock locks the file without waiting, it fails with ErrLocked if the lock is held elsewhere.
+	// Exclusive locks exclude every other lock, shared locks only exclusive ones. Closing the returned lock releases it.
+	Lock(name string, exclusive bool) (io.Closer, error)
+}
+
+/*
+WithReadOnly opens the tree files for reading only. Many read-only BufferManagers can use a file at the same time
+but no read-write one. Changes fail with ErrReadOnly and files are never created.
+*/
+func WithReadOnly(readOnly bool) Option {
+	return func(bm *BufferManager) {
+		bm.readOnly = readOnly
+	}
+}
+
+/*
+lockName returns the name of the lock file of the given tree file
+*/
+func lockName(fileID string) string {
+	return fileID + ".lock"
+}
+
+/*
+lock locks the given tree file until the BufferManager is closed or the file is deleted if it is not locked yet
+*/
+func (bm *BufferManager) lock(fileID string) error {
+	locker, ok := bm.storage.(Locker)
+	if !ok {
+		return nil
+	}
+	if _, ok := bm.locks[fileID]; ok {
+		return nil
+	}
+	lock, err := locker.Lock(lockName(fileID), !bm.readOnly)
+	if errors.Is(err, ErrLocked) {
+		if bm.readOnly {
+			return fmt.Errorf("%s cannot be read, it is opened for writing elsewhere: %w", fileID, err)
+		}
+		return fmt.Errorf("%s cannot be written, it is opened elsewhere: %w", fileID, err)
+	}
+	if err != nil {
+		return err
+	}
+	bm.locks[fileID] = lock
+	return nil
+}
+
+/*
+unlock releases the lock of the given tree file
+*/
+func (bm *BufferManager) unlock(fileID string) error {
+	lock, ok := bm.locks[fileID]
+	if !ok {
+		return nil
+	}
+	delete(bm.locks, fileID)
+	return lock.Close()
+}
+
+/*
+checkRecovered fails if the given file has a batch in its double write buffer, a read-only BufferManager cannot complete it
+*/
+func (bm *BufferManager) checkRecovered(fileID string) error {
+	exists, err := bm.storage.Exists(doubleWriteName(fileID))
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("%s has an interrupted write that has to be recovered read-write first: %w", fileID, ErrReadOnly)
+	}
+	return nil
+}
+
+/*
+nopLock is the lock of a Storage that cannot lock its files
+*/
+type nopLock struct{}
+
+func (nopLock) Close() error {
+	return nil
+}
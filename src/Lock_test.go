@@ -0,0 +1,148 @@
+package src
+
+import (
+	"errors"
+	"testing"
+)
+
+/*
+TestLockExcludesSecondWriter tests that a file can only be written by one BufferManager at a time
+*/
+func TestLockExcludesSecondWriter(t *testing.T) {
+	storage := NewMemoryStorage()
+	createTreeInStorage(t, storage, "testFileForLock")
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024), WithStorage(storage))
+	var otherBuffer, _ = CreateNewBufferManager("./", uint64(1024), WithStorage(storage))
+	var readOnlyBuffer, _ = CreateNewBufferManager("./", uint64(1024), WithStorage(storage), WithReadOnly(true))
+
+	_, err := myBuffer.Pin("testFileForLock", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := otherBuffer.Pin("testFileForLock", 0); !errors.Is(err, ErrLocked) {
+		t.Fatalf("a second writer got %v instead of ErrLocked", err)
+	}
+	if _, err := readOnlyBuffer.Pin("testFileForLock", 0); !errors.Is(err, ErrLocked) {
+		t.Fatalf("a reader got %v instead of ErrLocked while the file is written", err)
+	}
+
+	// closing a handle keeps the lock, only Close releases it
+	_ = myBuffer.Unpin(0, false)
+	_ = myBuffer.CloseFile("testFileForLock")
+	if _, err := otherBuffer.Pin("testFileForLock", 0); !errors.Is(err, ErrLocked) {
+		t.Fatalf("a second writer got %v after the handle has been closed", err)
+	}
+	_ = myBuffer.Close()
+	if _, err := otherBuffer.Pin("testFileForLock", 0); err != nil {
+		t.Fatalf("the file could not be locked after Close: %v", err)
+	}
+}
+
+/*
+TestLockReadOnly tests that read-only BufferManagers share a file and reject every change
+*/
+func TestLockReadOnly(t *testing.T) {
+	storage := NewMemoryStorage()
+	createTreeInStorage(t, storage, "testFileForReadOnly")
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024), WithStorage(storage), WithReadOnly(true))
+	var otherBuffer, _ = CreateNewBufferManager("./", uint64(1024), WithStorage(storage), WithReadOnly(true))
+	var writer, _ = CreateNewBufferManager("./", uint64(1024), WithStorage(storage))
+
+	var myLoader = Loader{}
+	tree, err := myLoader.Load("testFileForReadOnly", myBuffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = myLoader.Load("testFileForReadOnly", otherBuffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, err := tree.Get(11); err != nil || value != 12 {
+		t.Fatalf("Get(11) returned %d, %v on a read-only tree", value, err)
+	}
+	if _, err := writer.Pin("testFileForReadOnly", 0); !errors.Is(err, ErrLocked) {
+		t.Fatalf("a writer got %v instead of ErrLocked while the file is read", err)
+	}
+
+	if err := tree.Push(3, 4); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Push returned %v on a read-only tree", err)
+	}
+	pageID, _ := myBuffer.Pin("testFileForReadOnly", 1)
+	myBuffer.Pages[pageID].Keys[0] = 5
+	if err := myBuffer.Unpin(pageID, true); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Unpin of a modified page returned %v", err)
+	}
+	pageID, _ = myBuffer.Pin("testFileForReadOnly", 1)
+	if myBuffer.Pages[pageID].Keys[0] != 1 {
+		t.Errorf("the discarded modification is still in the buffer")
+	}
+	if _, err := myBuffer.AllocatePage("testFileForReadOnly"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("AllocatePage returned %v", err)
+	}
+	if err := myBuffer.SetRoot("testFileForReadOnly", 1, 0); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("SetRoot returned %v", err)
+	}
+	if _, err := myBuffer.Pin("testFileForReadOnlyMissing", 0); err == nil {
+		t.Error("pinning a missing file did not fail")
+	}
+	if exists, _ := storage.Exists("testFileForReadOnlyMissing"); exists {
+		t.Error("a read-only BufferManager has created a file")
+	}
+
+	_ = myBuffer.Close()
+	_ = otherBuffer.Close()
+	if _, err := writer.Pin("testFileForReadOnly", 0); err != nil {
+		t.Fatalf("the file could not be written after the readers have been closed: %v", err)
+	}
+}
+
+/*
+TestLockKeptOnEviction tests that a file evicted from the table of open files stays locked and keeps its cached pages
+*/
+func TestLockKeptOnEviction(t *testing.T) {
+	storage := NewMemoryStorage()
+	createTreeInStorage(t, storage, "testFileForEvictedLock")
+	createTreeInStorage(t, storage, "testFileForEvictingLock")
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024), WithStorage(storage), WithMaxOpenFiles(1))
+	var otherBuffer, _ = CreateNewBufferManager("./", uint64(1024), WithStorage(storage))
+	defer func() {
+		_ = otherBuffer.Close()
+	}()
+
+	pageID, _ := myBuffer.Pin("testFileForEvictedLock", 1)
+	myBuffer.Pages[pageID].Keys[0] = 5
+	_ = myBuffer.Unpin(pageID, true)
+	evictingID, err := myBuffer.Pin("testFileForEvictingLock", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = myBuffer.Unpin(evictingID, false)
+	if _, ok := myBuffer.files["testFileForEvictedLock"]; ok {
+		t.Fatal("the least recently used file has not been closed")
+	}
+	if _, err := otherBuffer.Pin("testFileForEvictedLock", 1); !errors.Is(err, ErrLocked) {
+		t.Fatalf("another BufferManager got %v instead of ErrLocked after the file has been evicted", err)
+	}
+
+	hits := myBuffer.Stats().Hits
+	pageID, err = myBuffer.Pin("testFileForEvictedLock", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if myBuffer.Stats().Hits != hits+1 || myBuffer.Pages[pageID].Keys[0] != 5 {
+		t.Error("the cached page of the evicted file has not been kept")
+	}
+	_ = myBuffer.Unpin(pageID, false)
+
+	if err := myBuffer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	pageID, err = otherBuffer.Pin("testFileForEvictedLock", 1)
+	if err != nil {
+		t.Fatalf("the file could not be locked after Close: %v", err)
+	}
+	if otherBuffer.Pages[pageID].Keys[0] != 5 {
+		t.Error("the dirty page of the evicted file has not been written back by Close")
+	}
+	_ = otherBuffer.Unpin(pageID, false)
+}
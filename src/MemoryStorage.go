@@ -0,0 +1,211 @@
+package src
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+)
+
+/*
+MemoryStorage keeps the files in memory, they are lost when the storage is dropped.
+It is meant for tests and caches that do not need to survive a restart.
+*/
+type MemoryStorage struct {
+	mu    sync.Mutex
+	files map[string]*memoryData
+	locks map[string]int // number of shared locks of a file, -1 if it is locked exclusively
+}
+
+/*
+memoryData is the content of one file, it is shared by all handles of the file
+*/
+type memoryData struct {
+	mu      sync.RWMutex
+	content []byte
+}
+
+/*
+grow extends the content with zeros to size bytes, append keeps the capacity growing geometrically
+*/
+func (d *memoryData) grow(size int64) {
+	d.content = append(d.content, make([]byte, size-int64(len(d.content)))...)
+}
+
+/*
+NewMemoryStorage creates an empty MemoryStorage
+*/
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{files: make(map[string]*memoryData), locks: make(map[string]int)}
+}
+
+func (s *MemoryStorage) Open(name string, create bool) (StorageFile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.files[name]
+	if !ok {
+		if !create {
+			return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+		}
+		data = &memoryData{}
+		s.files[name] = data
+	}
+	return &memoryFile{data: data}, nil
+}
+
+func (s *MemoryStorage) Remove(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.files[name]; !ok {
+		return &os.PathError{Op: "remove", Path: name, Err: os.ErrNotExist}
+	}
+	delete(s.files, name)
+	return nil
+}
+
+func (s *MemoryStorage) Rename(oldName string, newName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.files[oldName]
+	if !ok {
+		return &os.LinkError{Op: "rename", Old: oldName, New: newName, Err: os.ErrNotExist}
+	}
+	delete(s.files, oldName)
+	s.files[newName] = data
+	return nil
+}
+
+func (s *MemoryStorage) Exists(name string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.files[name]
+	return ok, nil
+}
+
+/*
+Lock locks the name within the MemoryStorage, the lock does not need a file
+*/
+func (s *MemoryStorage) Lock(name string, exclusive bool) (io.Closer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	held := s.locks[name]
+	if held < 0 || (exclusive && held > 0) {
+		return nil, ErrLocked
+	}
+	if exclusive {
+		s.locks[name] = -1
+	} else {
+		s.locks[name] = held + 1
+	}
+	return &memoryLock{storage: s, name: name, exclusive: exclusive}, nil
+}
+
+/*
+memoryLock is a lock held on a name of a MemoryStorage
+*/
+type memoryLock struct {
+	storage   *MemoryStorage
+	name      string
+	exclusive bool
+	released  bool
+}
+
+func (l *memoryLock) Close() error {
+	l.storage.mu.Lock()
+	defer l.storage.mu.Unlock()
+	if l.released {
+		return errors.New("lock is already released")
+	}
+	l.released = true
+	if l.exclusive || l.storage.locks[l.name] == 1 {
+		delete(l.storage.locks, l.name)
+	} else {
+		l.storage.locks[l.name]--
+	}
+	return nil
+}
+
+/*
+memoryFile is an open file of a MemoryStorage
+*/
+type memoryFile struct {
+	data   *memoryData
+	closed bool
+}
+
+var errFileClosed = errors.New("file is already closed")
+
+func (f *memoryFile) ReadAt(p []byte, off int64) (int, error) {
+	if f.closed {
+		return 0, errFileClosed
+	}
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	f.data.mu.RLock()
+	defer f.data.mu.RUnlock()
+	if off >= int64(len(f.data.content)) {
+		return 0, io.EOF
+	}
+	n := copy(p, f.data.content[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (f *memoryFile) WriteAt(p []byte, off int64) (int, error) {
+	if f.closed {
+		return 0, errFileClosed
+	}
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	f.data.mu.Lock()
+	defer f.data.mu.Unlock()
+	if end := off + int64(len(p)); end > int64(len(f.data.content)) {
+		f.data.grow(end)
+	}
+	return copy(f.data.content[off:], p), nil
+}
+
+func (f *memoryFile) Size() (int64, error) {
+	if f.closed {
+		return 0, errFileClosed
+	}
+	f.data.mu.RLock()
+	defer f.data.mu.RUnlock()
+	return int64(len(f.data.content)), nil
+}
+
+func (f *memoryFile) Truncate(size int64) error {
+	if f.closed {
+		return errFileClosed
+	}
+	if size < 0 {
+		return errors.New("negative size")
+	}
+	f.data.mu.Lock()
+	defer f.data.mu.Unlock()
+	if size > int64(len(f.data.content)) {
+		f.data.grow(size)
+	} else {
+		f.data.content = f.data.content[:size]
+	}
+	return nil
+}
+
+func (f *memoryFile) Sync() error {
+	if f.closed {
+		return errFileClosed
+	}
+	return nil
+}
+
+func (f *memoryFile) Close() error {
+	if f.closed {
+		return errFileClosed
+	}
+	f.closed = true
+	return nil
+}
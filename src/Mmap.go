@@ -0,0 +1,215 @@
+package src
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+/*
+WithMmap maps the binary tree files into memory instead of reading their pages with a system call, PinView returns
+a page inside the mapping without copying it. The Loader and the lookups and range scans of a BTree read mapped files
+through views, they take no frame of the buffer. It needs WithReadOnly because a mapped file must not be written, the
+files are locked shared and a pending double write buffer is refused like by every read-only BufferManager.
+Files with compressed or encrypted pages, text files and files of a Storage that cannot map them are read as usual.
+*/
+func WithMmap(mmap bool) Option {
+	return func(bm *BufferManager) {
+		bm.mmap = mmap
+	}
+}
+
+/*
+PageView is a page of a mapped file. It points into the mapping, nothing is read or copied.
+The file stays open until the view is released.
+*/
+type PageView struct {
+	Name       string
+	PageInFile uint64
+	data       []byte
+	bm         *BufferManager
+	file       *openFile
+}
+
+/*
+errViewReleased is returned when a PageView is released twice
+*/
+var errViewReleased = errors.New("the page view has already been released")
+
+/*
+PinView returns a view of the page pageInFile of the given file, the file has to be mapped, see WithMmap.
+The checksum of the page is verified, a mismatch is reported as CorruptionError.
+*/
+func (bm *BufferManager) PinView(fileID string, pageInFile uint64) (*PageView, error) {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	_, err := bm.file(fileID, false)
+	if err != nil {
+		return nil, err
+	}
+	handle := bm.files[fileID]
+	binaryFile, ok := handle.pageFile.(*binaryPageFile)
+	if !ok || binaryFile.mapper == nil {
+		return nil, fmt.Errorf("%s is not mapped into memory, see WithMmap", fileID)
+	}
+	data, err := binaryFile.mapped(pageInFile)
+	if err != nil {
+		return nil, err
+	}
+	if data[freeMarkOffset] == 1 {
+		return nil, fmt.Errorf("page %d of %s: %w", pageInFile, fileID, ErrFreePage)
+	}
+	// the mapping must stay valid until the view is released
+	handle.users++
+	bm.stats.Views++
+	return &PageView{Name: fileID, PageInFile: pageInFile, data: data, bm: bm, file: handle}, nil
+}
+
+/*
+isMapped reports if the pages of the given file can be read through PinView
+*/
+func (bm *BufferManager) isMapped(fileID string) bool {
+	if !bm.mmap {
+		return false
+	}
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	_, err := bm.file(fileID, false)
+	if err != nil {
+		return false
+	}
+	binaryFile, ok := bm.files[fileID].pageFile.(*binaryPageFile)
+	return ok && binaryFile.mapper != nil
+}
+
+/*
+Release gives the file of the view back, the view must not be used afterwards
+*/
+func (v *PageView) Release() error {
+	v.bm.mu.Lock()
+	defer v.bm.mu.Unlock()
+	if v.file == nil {
+		return errViewReleased
+	}
+	v.file.users--
+	v.file = nil
+	v.data = nil
+	return nil
+}
+
+/*
+Key returns the i-th key of the page
+*/
+func (v *PageView) Key(i int) uint64 {
+	return binary.LittleEndian.Uint64(v.data[keysOffset+8*i:])
+}
+
+/*
+Value returns the i-th value of the page
+*/
+func (v *PageView) Value(i int) uint64 {
+	return binary.LittleEndian.Uint64(v.data[valuesOffset+8*i:])
+}
+
+/*
+Page decodes a copy of the page
+*/
+func (v *PageView) Page() Page {
+	page := decodePage(v.data)
+	page.Name = v.Name
+	page.pageId = v.PageInFile
+	return page
+}
+
+/*
+mapFile maps the file into memory if its Storage supports it
+*/
+func (f *binaryPageFile) mapFile() error {
+	mapper, ok := f.file.(Mapper)
+	if !ok {
+		return nil
+	}
+	size, err := f.file.Size()
+	if err != nil {
+		return err
+	}
+	data, err := mapper.Map(size)
+	if err != nil {
+		return fmt.Errorf("%s cannot be mapped into memory: %w", f.name, err)
+	}
+	f.mapper = mapper
+	f.mapping = data
+	return nil
+}
+
+/*
+mapped returns the encoded page inside the mapping and verifies its checksum.
+A page behind the end of the mapping is only found if the file has grown since it has been mapped.
+*/
+func (f *binaryPageFile) mapped(pageInFile uint64) ([]byte, error) {
+	end := offset(pageInFile) + PageSize
+	f.mu.RLock()
+	mapping := f.mapping
+	f.mu.RUnlock()
+	if int64(len(mapping)) < end {
+		var err error
+		mapping, err = f.remap(pageInFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	data := mapping[offset(pageInFile):end]
+	if !validChecksum(data) {
+		return nil, &CorruptionError{File: f.name, Page: pageInFile, Reason: "checksum mismatch"}
+	}
+	return data, nil
+}
+
+/*
+remap maps the file again if it has grown to contain the page. The old mapping is kept until the file is
+closed because views may still point into it.
+*/
+func (f *binaryPageFile) remap(pageInFile uint64) ([]byte, error) {
+	end := offset(pageInFile) + PageSize
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	// another read may have remapped the file in the meantime
+	if int64(len(f.mapping)) >= end {
+		return f.mapping, nil
+	}
+	size, err := f.file.Size()
+	if err != nil {
+		return nil, err
+	}
+	if size < end {
+		return nil, fmt.Errorf("page %d is not part of %s", pageInFile, f.name)
+	}
+	data, err := f.mapper.Map(size)
+	if err != nil {
+		return nil, fmt.Errorf("%s cannot be mapped into memory again: %w", f.name, err)
+	}
+	f.retired = append(f.retired, f.mapping)
+	f.mapping = data
+	return data, nil
+}
+
+/*
+unmap releases every mapping of the file
+*/
+func (f *binaryPageFile) unmap() error {
+	if f.mapper == nil {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var firstErr error
+	for _, data := range append(f.retired, f.mapping) {
+		err := f.mapper.Unmap(data)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	f.mapping = nil
+	f.retired = nil
+	return firstErr
+}
@@ -0,0 +1,248 @@
+//go:build unix
+
+package src
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+)
+
+/*
+TestMmapPinView tests that PinView returns views into the mapping of a binary file and keeps the file open
+*/
+func TestMmapPinView(t *testing.T) {
+	createTreeInStorage(t, NewDiskStorage("./"), "testFileForMapping")
+	defer func() {
+		_ = os.Remove("./testFileForMapping")
+	}()
+	var myBuffer, err = CreateNewBufferManager("./", uint64(1024), WithReadOnly(true), WithMmap(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = myBuffer.Close()
+	}()
+
+	root, err := myBuffer.PinView("testFileForMapping", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root.Key(0) != 10 || root.Value(0) != 1 || root.Value(1) != 2 {
+		t.Errorf("root view has keys %v and values %v", root.Page().Keys, root.Page().Values)
+	}
+	leaf, err := myBuffer.PinView("testFileForMapping", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if leaf.Key(0) != 11 || leaf.Value(0) != 12 || leaf.Page().pageId != 2 {
+		t.Errorf("leaf view has keys %v and values %v", leaf.Page().Keys, leaf.Page().Values)
+	}
+	again, _ := myBuffer.PinView("testFileForMapping", 2)
+	if &again.data[0] != &leaf.data[0] {
+		t.Error("pinning a page twice returned different memory")
+	}
+	if _, err := myBuffer.PinView("testFileForMapping", 3); err == nil {
+		t.Error("pinning a page after the end of the file did not fail")
+	}
+
+	// pages are read from the mapping as well
+	pageID, err := myBuffer.Pin("testFileForMapping", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if myBuffer.Pages[pageID].Keys[0] != 1 || myBuffer.Pages[pageID].Values[0] != 2 {
+		t.Errorf("Pin of a mapped page returned %+v", myBuffer.Pages[pageID])
+	}
+	_ = myBuffer.Unpin(pageID, false)
+
+	if err := myBuffer.CloseFile("testFileForMapping"); err == nil {
+		t.Error("a file with views has been closed")
+	}
+	for _, view := range []*PageView{root, leaf, again} {
+		if err := view.Release(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := root.Release(); !errors.Is(err, errViewReleased) {
+		t.Errorf("releasing a view twice returned %v", err)
+	}
+	if err := myBuffer.CloseFile("testFileForMapping"); err != nil {
+		t.Errorf("the file could not be closed after its views have been released: %v", err)
+	}
+}
+
+/*
+TestMmapNeedsReadOnly tests that a BufferManager that can write does not map its files
+*/
+func TestMmapNeedsReadOnly(t *testing.T) {
+	if _, err := CreateNewBufferManager("./", uint64(1024), WithMmap(true)); err == nil {
+		t.Error("WithMmap without WithReadOnly has been accepted")
+	}
+}
+
+/*
+TestMmapRejectsCorruptAndTextFiles tests that corrupt pages are reported and text files are not mapped
+*/
+func TestMmapRejectsCorruptAndTextFiles(t *testing.T) {
+	createTreeInStorage(t, NewDiskStorage("./"), "testFileForMappingCorrupt")
+	createFileWithPages(t, "testFileForMappingText", 2)
+	defer func() {
+		_ = os.Remove("./testFileForMappingCorrupt")
+		_ = os.Remove("./testFileForMappingText")
+	}()
+	file, _ := os.OpenFile("./testFileForMappingCorrupt", os.O_RDWR, 0644)
+	_, _ = file.WriteAt([]byte{0xff}, offset(1)+keysOffset)
+	_ = file.Close()
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024), WithReadOnly(true), WithMmap(true))
+	defer func() {
+		_ = myBuffer.Close()
+	}()
+
+	var corruption *CorruptionError
+	if _, err := myBuffer.PinView("testFileForMappingCorrupt", 1); !errors.As(err, &corruption) || corruption.Page != 1 {
+		t.Errorf("pinning a corrupt page returned %v", err)
+	}
+	if _, err := myBuffer.Pin("testFileForMappingCorrupt", 1); !errors.As(err, &corruption) {
+		t.Errorf("reading a corrupt page returned %v", err)
+	}
+	view, err := myBuffer.PinView("testFileForMappingCorrupt", 0)
+	if err != nil {
+		t.Errorf("pinning an intact page returned %v", err)
+	} else {
+		_ = view.Release()
+	}
+	if _, err := myBuffer.PinView("testFileForMappingText", 0); err == nil {
+		t.Error("a view of a text file has been returned")
+	}
+	pageID, err := myBuffer.Pin("testFileForMappingText", 0)
+	if err != nil {
+		t.Errorf("a text file could not be read: %v", err)
+	} else {
+		_ = myBuffer.Unpin(pageID, false)
+	}
+}
+
+/*
+TestMmapLocks tests that a mapped file cannot be written by another BufferManager and that a pending double write buffer is refused
+*/
+func TestMmapLocks(t *testing.T) {
+	createTreeInStorage(t, NewDiskStorage("./"), "testFileForMappingLock")
+	defer func() {
+		_ = os.Remove("./testFileForMappingLock")
+		_ = os.Remove("./" + doubleWriteName("testFileForMappingLock"))
+	}()
+	var writer, _ = CreateNewBufferManager("./", uint64(1024))
+	defer func() {
+		_ = writer.Close()
+	}()
+	var mapped, _ = CreateNewBufferManager("./", uint64(1024), WithReadOnly(true), WithMmap(true))
+
+	view, err := mapped.PinView("testFileForMappingLock", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writer.Pin("testFileForMappingLock", 0); !errors.Is(err, ErrLocked) {
+		t.Fatalf("a writer got %v instead of ErrLocked while the file is mapped", err)
+	}
+	_ = view.Release()
+	_ = mapped.Close()
+	pageID, err := writer.Pin("testFileForMappingLock", 0)
+	if err != nil {
+		t.Fatalf("the file could not be written after it has been unmapped: %v", err)
+	}
+	_ = writer.Unpin(pageID, false)
+
+	mapped, _ = CreateNewBufferManager("./", uint64(1024), WithReadOnly(true), WithMmap(true))
+	if _, err := mapped.PinView("testFileForMappingLock", 0); !errors.Is(err, ErrLocked) {
+		t.Fatalf("mapping a file that is written elsewhere returned %v instead of ErrLocked", err)
+	}
+	_ = writer.Close()
+
+	if err := os.WriteFile("./"+doubleWriteName("testFileForMappingLock"), []byte{1}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mapped.PinView("testFileForMappingLock", 0); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("mapping a file with an interrupted write returned %v instead of ErrReadOnly", err)
+	}
+	_ = mapped.Close()
+}
+
+/*
+TestMmapRemapsGrownFile tests that pages appended after the file has been mapped are found and older views stay valid
+*/
+func TestMmapRemapsGrownFile(t *testing.T) {
+	createTreeInStorage(t, NewDiskStorage("./"), "testFileForMappingGrowth")
+	defer func() {
+		_ = os.Remove("./testFileForMappingGrowth")
+	}()
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024), WithReadOnly(true), WithMmap(true))
+	defer func() {
+		_ = myBuffer.Close()
+	}()
+	leaf, err := myBuffer.PinView("testFileForMappingGrowth", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = leaf.Release()
+	}()
+
+	buf := make([]byte, PageSize)
+	encodePage(Page{Keys: [6]uint64{13}, Values: [7]uint64{14}}, buf)
+	file, _ := os.OpenFile("./testFileForMappingGrowth", os.O_RDWR, 0644)
+	_, _ = file.WriteAt(buf, offset(3))
+	_ = file.Close()
+
+	appended, err := myBuffer.PinView("testFileForMappingGrowth", 3)
+	if err != nil {
+		t.Fatalf("a page appended after mapping the file returned %v", err)
+	}
+	if appended.Key(0) != 13 || appended.Value(0) != 14 {
+		t.Errorf("appended view has keys %v and values %v", appended.Page().Keys, appended.Page().Values)
+	}
+	_ = appended.Release()
+	if leaf.Key(0) != 11 || leaf.Value(0) != 12 {
+		t.Errorf("the view from before the remap has keys %v and values %v", leaf.Page().Keys, leaf.Page().Values)
+	}
+}
+
+/*
+TestMmapBTreeReadsViews tests that lookups and range scans of a tree in a mapped file read views instead of pinning frames
+*/
+func TestMmapBTreeReadsViews(t *testing.T) {
+	createTreeInStorage(t, NewDiskStorage("./"), "testFileForMappedTree")
+	defer func() {
+		_ = os.Remove("./testFileForMappedTree")
+	}()
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024), WithReadOnly(true), WithMmap(true))
+	defer func() {
+		_ = myBuffer.Close()
+	}()
+	var myLoader = Loader{}
+	tree, err := myLoader.Load("testFileForMappedTree", myBuffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	value, err := tree.Get(11)
+	if err != nil || value != 12 {
+		t.Errorf("Get returned %d, %v instead of 12", value, err)
+	}
+	if _, err := tree.Get(5); err != errKeyNotFound {
+		t.Errorf("Get of a missing key returned %v", err)
+	}
+	result, err := tree.GetRange(0, 100)
+	if err != nil || !reflect.DeepEqual(result, map[uint64]uint64{1: 2, 11: 12}) {
+		t.Errorf("GetRange returned %v, %v", result, err)
+	}
+
+	stats := myBuffer.Stats()
+	if stats.Pins != 0 || stats.Views == 0 {
+		t.Errorf("the mapped tree has been read with %d pins and %d views", stats.Pins, stats.Views)
+	}
+	if myBuffer.files["testFileForMappedTree"].users != 0 {
+		t.Error("a view of the tree has not been released")
+	}
+}
@@ -1,5 +1,19 @@
 package src
 
+/*
+PageSize is the amount of memory in bytes one page takes up in the buffer.
+The memory budget of a BufferManager is divided by it to get the number of frames.
+*/
+const PageSize = 128
+
+/*
+PageKey identifies a page across all files served by one BufferManager
+*/
+type PageKey struct {
+	File string // the name of the file the page belongs to
+	Page uint64 // the number of the page within the file
+}
+
 /*
 Page definition with its keys and values
 For root and non-leaf nodes we use all the 7 values.
@@ -11,3 +25,24 @@ type Page struct {
 	Keys   [6]uint64
 	Values [7]uint64
 }
+
+/*
+key returns the PageKey the page is cached under
+*/
+func (p Page) key() PageKey {
+	return PageKey{File: p.Name, Page: p.pageId}
+}
+
+/*
+Key returns the i-th key of the page
+*/
+func (p Page) Key(i int) uint64 {
+	return p.Keys[i]
+}
+
+/*
+Value returns the i-th value of the page
+*/
+func (p Page) Value(i int) uint64 {
+	return p.Values[i]
+}
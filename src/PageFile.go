@@ -0,0 +1,542 @@
+package src
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/*
+pageFile reads and writes single pages of one tree file. There is one implementation for the
+binary format with fixed-size pages and one for the semicolon separated text format.
+*/
+type pageFile interface {
+	// ReadPage reads the page pageInFile from the file
+	ReadPage(pageInFile uint64) (Page, error)
+	// WritePage writes the page to its place in the file, a page right after the last one grows the file
+	WritePage(page Page) error
+	// WritePages writes the pages at once, either all of them or none are on disk after a crash
+	WritePages(pages []Page) error
+	// PageCount returns the number of pages in the file
+	PageCount() (uint64, error)
+	// Header returns the header of the file
+	Header() FileHeader
+	// SetRoot stores a new root page and height in the header
+	SetRoot(root uint64, height uint64) error
+	// Allocate takes a page off the free list or appends one, the page is empty on disk
+	Allocate() (uint64, error)
+	// Free clears the page and puts it on the free list
+	Free(pageInFile uint64) error
+	// FreePages returns the pages on the free list in the order they are reused
+	FreePages() ([]uint64, error)
+	// Sync makes everything written to the file durable
+	Sync() error
+	// Close releases the file
+	Close() error
+}
+
+/*
+openPageFile opens the given tree file in the format it has been written in. Files that start with
+the magic number are binary files with a header, files without it are legacy text files.
+A file that does not exist yet is created in the binary format when create is set, an empty file only gets a new header then.
+*/
+func (bm *BufferManager) openPageFile(fileID string, create bool) (pageFile, error) {
+	file, err := bm.storage.Open(fileID, create)
+	if err != nil {
+		return nil, err
+	}
+
+	if bm.readOnly {
+		err = bm.checkRecovered(fileID)
+		if err != nil {
+			_ = file.Close()
+			return nil, err
+		}
+	}
+	// a batch of pages that has been interrupted by a crash is completed before anything is read
+	err = replayDoubleWrite(bm.storage, file, fileID)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	buf := make([]byte, PageSize)
+	n, err := file.ReadAt(buf, 0)
+	if err != nil && err != io.EOF {
+		_ = file.Close()
+		return nil, err
+	}
+	if n == 0 && !create {
+		// reading must not modify the file, it only gets a header when a page is allocated
+		_ = file.Close()
+		return nil, fmt.Errorf("%s is empty and has no tree yet: %w", fileID, os.ErrNotExist)
+	}
+	if n == 0 {
+		binaryFile := &binaryPageFile{name: fileID, storage: bm.storage, file: file, header: newHeader(), sync: bm.sync}
+		if bm.compress || bm.encryptionKey != nil {
+			err = bm.initSlots(binaryFile, nil)
+			if err != nil {
+				_ = file.Close()
+				return nil, err
+			}
+		}
+		err = binaryFile.writeHeader()
+		if err != nil {
+			_ = file.Close()
+			return nil, err
+		}
+		return binaryFile, nil
+	}
+	if bytes.HasPrefix(buf[:n], fileMagic[:]) {
+		header, err := decodeHeader(fileID, buf[:n])
+		if err != nil {
+			_ = file.Close()
+			return nil, err
+		}
+		binaryFile := &binaryPageFile{name: fileID, storage: bm.storage, file: file, header: header, sync: bm.sync}
+		if header.Compressed || header.Encrypted {
+			err = bm.initSlots(binaryFile, buf)
+		} else if bm.mmap {
+			err = binaryFile.mapFile()
+		}
+		if err != nil {
+			_ = file.Close()
+			return nil, err
+		}
+		return binaryFile, nil
+	}
+	// the text format only consists of digits, semicolons and line breaks
+	if bytes.IndexByte(buf[:n], 0) >= 0 {
+		_ = file.Close()
+		return nil, fmt.Errorf("%s has an unknown format, it is neither a binary tree file nor a text file", fileID)
+	}
+	// the text file is rewritten as a whole on every write, the handle is not needed after loading it
+	text := &textPageFile{name: fileID, storage: bm.storage, sync: bm.sync}
+	err = text.load(file)
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return text, nil
+}
+
+/*
+The binary format stores page n at offset (n + 1) * PageSize behind the header. The 6 keys are followed
+by the 7 values, each as a little endian uint64. A page on the free list has no keys and values but the
+next page of the list and a marker behind them. The last 4 bytes hold the CRC32C checksum of everything
+before them, the bytes in between are padding.
+*/
+const (
+	keysOffset     = 0
+	valuesOffset   = keysOffset + 6*8
+	pageDataSize   = valuesOffset + 7*8
+	freeNextOffset = pageDataSize
+	freeMarkOffset = freeNextOffset + 8
+	checksumOffset = PageSize - 4
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+/*
+CorruptionError is returned when a page read from disk does not match what has been written
+*/
+type CorruptionError struct {
+	File   string
+	Page   uint64
+	Reason string
+}
+
+func (e *CorruptionError) Error() string {
+	if e.Page == noPage {
+		return fmt.Sprintf("the header of %s is corrupt: %s", e.File, e.Reason)
+	}
+	return fmt.Sprintf("page %d of %s is corrupt: %s", e.Page, e.File, e.Reason)
+}
+
+/*
+binaryPageFile accesses the pages of a binary tree file directly at their offset
+*/
+type binaryPageFile struct {
+	name    string
+	storage Storage
+	file    StorageFile
+	header  FileHeader
+	sync    bool // sync every batch of writes to disk before returning
+
+	slotted  bool         // the pages are stored in slots, see Slots.go
+	compress bool         // the slots are compressed
+	cipher   *pageCipher  // encrypts the slots, nil if they are not encrypted
+	mu       sync.RWMutex // guards slots, cipher and the mappings, pages are read without the latch of the page table
+	slots    *slotTable   // place of every page of a file with slots
+
+	mapper  Mapper   // maps the file into memory, nil if it is read with ReadAt, see Mmap.go
+	mapping []byte   // the current mapping of the file
+	retired [][]byte // mappings replaced after the file has grown, views may still point into them
+}
+
+/*
+offset returns the position of the page in the file
+*/
+func offset(pageInFile uint64) int64 {
+	return int64((pageInFile + 1) * PageSize)
+}
+
+func (f *binaryPageFile) ReadPage(pageInFile uint64) (Page, error) {
+	buf, err := f.readRaw(pageInFile)
+	if err != nil {
+		return Page{}, err
+	}
+	// a free page must not be used, writing it back would cut the free list
+	if buf[freeMarkOffset] == 1 {
+		return Page{}, fmt.Errorf("page %d of %s: %w", pageInFile, f.name, ErrFreePage)
+	}
+	page := decodePage(buf)
+	page.Name = f.name
+	page.pageId = pageInFile
+	return page, nil
+}
+
+/*
+readRaw reads the encoded page and verifies its checksum, the page of a mapped file points into the mapping and must not be modified
+*/
+func (f *binaryPageFile) readRaw(pageInFile uint64) ([]byte, error) {
+	if f.slotted {
+		return f.readSlot(pageInFile)
+	}
+	if f.mapper != nil {
+		return f.mapped(pageInFile)
+	}
+	buf := make([]byte, PageSize)
+	_, err := f.file.ReadAt(buf, offset(pageInFile))
+	if err == io.EOF {
+		return nil, fmt.Errorf("page %d is not part of %s", pageInFile, f.name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !validChecksum(buf) {
+		return nil, &CorruptionError{File: f.name, Page: pageInFile, Reason: "checksum mismatch"}
+	}
+	return buf, nil
+}
+
+func (f *binaryPageFile) WritePage(page Page) error {
+	return f.WritePages([]Page{page})
+}
+
+func (f *binaryPageFile) WritePages(pages []Page) error {
+	records, err := f.pageRecords(pages)
+	if err != nil {
+		return err
+	}
+	return f.writeRecords(records)
+}
+
+/*
+pageRecords encodes the pages for writeRecords, pages may only grow the file without leaving a gap
+*/
+func (f *binaryPageFile) pageRecords(pages []Page) ([]doubleWriteRecord, error) {
+	count, err := f.PageCount()
+	if err != nil {
+		return nil, err
+	}
+	sorted := append([]Page(nil), pages...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].pageId < sorted[j].pageId })
+	records := make([]doubleWriteRecord, 0, len(sorted))
+	for _, page := range sorted {
+		if page.pageId > count {
+			return nil, fmt.Errorf("page %d cannot be written, %s only has %d pages", page.pageId, f.name, count)
+		}
+		if page.pageId == count {
+			count++
+		}
+		buf := make([]byte, PageSize)
+		encodePage(page, buf)
+		records = append(records, doubleWriteRecord{offset: offset(page.pageId), buf: buf})
+	}
+	return records, nil
+}
+
+func (f *binaryPageFile) PageCount() (uint64, error) {
+	if f.slotted {
+		f.mu.RLock()
+		defer f.mu.RUnlock()
+		return uint64(len(f.slots.slots)), nil
+	}
+	size, err := f.file.Size()
+	if err != nil {
+		return 0, err
+	}
+	if size < PageSize {
+		return 0, nil
+	}
+	return uint64(size)/PageSize - 1, nil
+}
+
+func (f *binaryPageFile) Header() FileHeader {
+	return f.header
+}
+
+func (f *binaryPageFile) SetRoot(root uint64, height uint64) error {
+	f.header.Root = root
+	f.header.Height = height
+	return f.writeHeader()
+}
+
+/*
+writeHeader writes the header in front of the first page
+*/
+func (f *binaryPageFile) writeHeader() error {
+	return f.writeRecords([]doubleWriteRecord{f.headerRecord()})
+}
+
+/*
+headerRecord encodes the header for writeRecords
+*/
+func (f *binaryPageFile) headerRecord() doubleWriteRecord {
+	buf := make([]byte, PageSize)
+	encodeHeader(f.header, buf)
+	return doubleWriteRecord{offset: 0, buf: buf}
+}
+
+func (f *binaryPageFile) Sync() error {
+	return f.file.Sync()
+}
+
+func (f *binaryPageFile) Close() error {
+	err := f.unmap()
+	closeErr := f.file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+/*
+encodePage writes the keys and values of the page and its checksum into buf which has to be PageSize bytes long
+*/
+func encodePage(page Page, buf []byte) {
+	for i := 0; i < len(page.Keys); i++ {
+		binary.LittleEndian.PutUint64(buf[keysOffset+8*i:], page.Keys[i])
+	}
+	for i := 0; i < len(page.Values); i++ {
+		binary.LittleEndian.PutUint64(buf[valuesOffset+8*i:], page.Values[i])
+	}
+	setChecksum(buf)
+}
+
+/*
+setChecksum stores the checksum of the encoded page or header in its last bytes
+*/
+func setChecksum(buf []byte) {
+	binary.LittleEndian.PutUint32(buf[checksumOffset:], crc32.Checksum(buf[:checksumOffset], castagnoli))
+}
+
+/*
+validChecksum reports if the checksum stored in the encoded page matches its content
+*/
+func validChecksum(buf []byte) bool {
+	return binary.LittleEndian.Uint32(buf[checksumOffset:]) == crc32.Checksum(buf[:checksumOffset], castagnoli)
+}
+
+/*
+decodePage reads the keys and values of a page out of buf
+*/
+func decodePage(buf []byte) Page {
+	page := Page{}
+	for i := 0; i < len(page.Keys); i++ {
+		page.Keys[i] = binary.LittleEndian.Uint64(buf[keysOffset+8*i:])
+	}
+	for i := 0; i < len(page.Values); i++ {
+		page.Values[i] = binary.LittleEndian.Uint64(buf[valuesOffset+8*i:])
+	}
+	return page
+}
+
+/*
+textPageFile accesses a tree file in the text format where every line is one page with 13 fields separated by ';'.
+Lines have different lengths, so the lines are read once when the file is opened and every write rewrites the whole file.
+*/
+type textPageFile struct {
+	name    string
+	storage Storage
+	mu      sync.RWMutex // guards rows and free, pages are read without the latch of the page table
+	rows    []string
+	free    map[uint64]bool // pages on the free list, ReadPage refuses them like for binary files
+	sync    bool            // sync every rewrite of the file to disk before returning
+}
+
+func (f *textPageFile) ReadPage(pageInFile uint64) (Page, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if pageInFile >= uint64(len(f.rows)) {
+		return Page{}, errors.New("deserialization failed, the page is not part of the file")
+	}
+	if f.free[pageInFile] {
+		return Page{}, fmt.Errorf("page %d of %s: %w", pageInFile, f.name, ErrFreePage)
+	}
+	return f.readRow(pageInFile)
+}
+
+/*
+readRow parses the line of the page, also if the page is on the free list. The caller holds mu.
+*/
+func (f *textPageFile) readRow(pageInFile uint64) (Page, error) {
+	page, field, err := parseTextRow(f.rows[pageInFile])
+	if err != nil {
+		if field > 0 {
+			return Page{}, &CorruptionError{File: f.name, Page: pageInFile, Reason: fmt.Sprintf("field %d: %v", field, err)}
+		}
+		return Page{}, &CorruptionError{File: f.name, Page: pageInFile, Reason: err.Error()}
+	}
+	page.Name = f.name
+	page.pageId = pageInFile
+	return page, nil
+}
+
+func (f *textPageFile) WritePage(page Page) error {
+	return f.WritePages([]Page{page})
+}
+
+/*
+WritePages rewrites the whole file once through a temporary file that replaces it
+*/
+func (f *textPageFile) WritePages(pages []Page) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	rows := append([]string(nil), f.rows...)
+	sorted := append([]Page(nil), pages...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].pageId < sorted[j].pageId })
+	for _, page := range sorted {
+		if page.pageId > uint64(len(rows)) {
+			return fmt.Errorf("page %d cannot be written, %s only has %d pages", page.pageId, f.name, len(rows))
+		}
+		if page.pageId == uint64(len(rows)) {
+			rows = append(rows, encodeRow(page))
+		} else {
+			rows[page.pageId] = encodeRow(page)
+		}
+	}
+	err := writeFileAtomic(f.storage, f.name, []byte(strings.Join(rows, "\n")), f.sync)
+	if err != nil {
+		return err
+	}
+	f.rows = rows
+	return nil
+}
+
+func (f *textPageFile) PageCount() (uint64, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return uint64(len(f.rows)), nil
+}
+
+func (f *textPageFile) Header() FileHeader {
+	return legacyHeader()
+}
+
+func (f *textPageFile) SetRoot(root uint64, height uint64) error {
+	return fmt.Errorf("%s is a legacy text file with a fixed root, convert it to the binary format first", f.name)
+}
+
+/*
+Sync syncs the file that has been written last, text files are not kept open
+*/
+func (f *textPageFile) Sync() error {
+	file, err := f.storage.Open(f.name, false)
+	if err != nil {
+		return err
+	}
+	err = file.Sync()
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (f *textPageFile) Close() error {
+	return nil
+}
+
+/*
+load reads the lines of the file, an empty file has no pages
+*/
+func (f *textPageFile) load(file StorageFile) error {
+	dat, err := readAll(file)
+	if err != nil {
+		return err
+	}
+	f.rows = nil
+	if len(dat) > 0 {
+		f.rows = strings.Split(strings.TrimSuffix(string(dat), "\n"), "\n")
+	}
+	freeList, err := f.FreePages()
+	if err != nil {
+		return err
+	}
+	f.setFree(freeList)
+	return nil
+}
+
+/*
+parseTextRow parses one line of the text format and validates every field.
+If the row is malformed the number of the offending field is returned, 0 if the number of fields is wrong.
+*/
+func parseTextRow(row string) (Page, int, error) {
+	stringArray := strings.Split(row, ";")
+	if len(stringArray) != 13 {
+		return Page{}, 0, fmt.Errorf("row contains %d instead of 13 fields", len(stringArray))
+	}
+
+	page := Page{}
+	for i, field := range stringArray {
+		if field == "" {
+			continue
+		}
+		value, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return Page{}, i + 1, fmt.Errorf("%q is not a valid unsigned integer", field)
+		}
+		if i < len(page.Keys) {
+			page.Keys[i] = value
+		} else {
+			page.Values[i-len(page.Keys)] = value
+		}
+	}
+	return page, 0, nil
+}
+
+/*
+encodeRow formats the page as one line of the text format, zero keys and values are left empty
+*/
+func encodeRow(page Page) string {
+	var outputString = ""
+	for i := 0; i < len(page.Keys); i++ {
+		if tmpKey := page.Keys[i]; tmpKey != 0 {
+			outputString = outputString + strconv.FormatUint(page.Keys[i], 10)
+		}
+		outputString = outputString + ";"
+	}
+
+	for i := 0; i < len(page.Values); i++ {
+		if tmpValue := page.Values[i]; tmpValue != 0 {
+			outputString = outputString + strconv.FormatUint(page.Values[i], 10)
+		}
+		if i == len(page.Values)-1 {
+			break
+		}
+		outputString = outputString + ";"
+	}
+	return outputString
+}
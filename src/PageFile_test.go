@@ -0,0 +1,132 @@
+package src
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+/*
+TestPageFileBinaryRoundTrip tests that pages written in the binary format are read back unchanged at fixed offsets
+*/
+func TestPageFileBinaryRoundTrip(t *testing.T) {
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024))
+	defer func() {
+		_ = os.Remove("./testFileForBinary")
+		_ = os.Remove("./" + freeListName("testFileForBinary"))
+	}()
+	defer func() {
+		_ = myBuffer.Close()
+	}()
+
+	for i := 0; i < 3; i++ {
+		_, err := myBuffer.AllocatePage("testFileForBinary")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	info, _ := os.Stat("./testFileForBinary")
+	if info.Size() != 4*PageSize {
+		t.Fatalf("binary file has %d bytes instead of %d", info.Size(), 4*PageSize)
+	}
+
+	file, err := myBuffer.openPageFile("testFileForBinary", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := file.(*binaryPageFile); !ok {
+		t.Fatal("new file has not been created in the binary format")
+	}
+	page := Page{Name: "testFileForBinary", pageId: 1, Keys: [6]uint64{1, 2, 3, 4, 5, 1 << 63}, Values: [7]uint64{7, 8, 9, 10, 11, 12, 13}}
+	err = file.WritePage(page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = file.Close()
+
+	id, err := myBuffer.Pin("testFileForBinary", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if myBuffer.Pages[id] != page {
+		t.Fatalf("read %v instead of %v", myBuffer.Pages[id], page)
+	}
+	id, _ = myBuffer.Pin("testFileForBinary", 2)
+	if myBuffer.Pages[id].Keys != [6]uint64{} {
+		t.Fatal("writing page 1 has changed page 2")
+	}
+
+	_, err = myBuffer.Pin("testFileForBinary", 3)
+	if err == nil {
+		t.Fatal("pinning a page after the end of the file should return an error but does not")
+	}
+}
+
+/*
+TestPageFileDetectsTextFormat tests that files in the text format are still read and written as text
+*/
+func TestPageFileDetectsTextFormat(t *testing.T) {
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024))
+	createFileWithPages(t, "testFileForTextFormat", 2)
+	defer func() {
+		_ = os.Remove("./testFileForTextFormat")
+	}()
+	defer func() {
+		_ = myBuffer.Close()
+	}()
+
+	file, err := myBuffer.openPageFile("testFileForTextFormat", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := file.(*textPageFile); !ok {
+		t.Fatal("text file has not been detected")
+	}
+	_ = file.Close()
+
+	id, _ := myBuffer.Pin("testFileForTextFormat", 1)
+	myBuffer.Pages[id].Values[0] = 42
+	_ = myBuffer.Unpin(id, true)
+	err = myBuffer.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dat, _ := os.ReadFile("./testFileForTextFormat")
+	if string(dat) != "1;;;;;;100;;;;;;\n2;;;;;;42;;;;;;" {
+		t.Fatalf("text file contains %q after flush", string(dat))
+	}
+}
+
+/*
+TestPageFileDetectsBitFlip tests that a page modified on disk behind the back of the BufferManager is reported as corrupt
+*/
+func TestPageFileDetectsBitFlip(t *testing.T) {
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024))
+	defer func() {
+		_ = os.Remove("./testFileForBitFlip")
+		_ = os.Remove("./" + freeListName("testFileForBitFlip"))
+	}()
+	defer func() {
+		_ = myBuffer.Close()
+	}()
+	_, _ = myBuffer.AllocatePage("testFileForBitFlip")
+	_, _ = myBuffer.AllocatePage("testFileForBitFlip")
+	_ = myBuffer.Close()
+
+	dat, _ := os.ReadFile("./testFileForBitFlip")
+	dat[2*PageSize+valuesOffset] ^= 0x04
+	_ = os.WriteFile("./testFileForBitFlip", dat, 0644)
+
+	if _, err := myBuffer.Pin("testFileForBitFlip", 0); err != nil {
+		t.Fatalf("intact page has been reported as corrupt: %v", err)
+	}
+	_, err := myBuffer.Pin("testFileForBitFlip", 1)
+	var corruptionError *CorruptionError
+	if !errors.As(err, &corruptionError) {
+		t.Fatalf("expected a CorruptionError but got %v", err)
+	}
+	if corruptionError.File != "testFileForBitFlip" || corruptionError.Page != 1 {
+		t.Fatalf("error names page %d of %s instead of page 1 of testFileForBitFlip", corruptionError.Page, corruptionError.File)
+	}
+}
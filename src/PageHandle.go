@@ -0,0 +1,143 @@
+package src
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+/*
+ErrHandleReleased is returned when a PageHandle is released a second time or used after it has been released
+*/
+var ErrHandleReleased = errors.New("the page handle has already been released")
+
+/*
+ErrLeakedPins is returned by Close when pages are still pinned
+*/
+var ErrLeakedPins = errors.New("pages are still pinned")
+
+/*
+PageHandle is a pinned page, the pin is held until Release is called exactly once.
+Modifications are written back if the page has been marked dirty. Builds with the tag dmdsdebug panic on a second
+Release and on every use after Release, and Close reports where the leaked handles have been pinned.
+*/
+type PageHandle struct {
+	bm       *BufferManager
+	pageID   uint64
+	key      PageKey
+	dirty    bool
+	released bool
+	pinnedAt string // caller of PinHandle, only recorded in debug builds
+}
+
+/*
+PinHandle pins the page pageInFile of the given file and returns a handle that has to be released
+*/
+func (bm *BufferManager) PinHandle(fileID string, pageInFile uint64) (*PageHandle, error) {
+	pageID, err := bm.Pin(fileID, pageInFile)
+	if err != nil {
+		return nil, err
+	}
+	h := &PageHandle{bm: bm, pageID: pageID, key: PageKey{File: fileID, Page: pageInFile}}
+	if debugHandles {
+		if _, file, line, ok := runtime.Caller(1); ok {
+			h.pinnedAt = fmt.Sprintf("%s:%d", file, line)
+		}
+		bm.mu.Lock()
+		bm.handles[h] = struct{}{}
+		bm.mu.Unlock()
+	}
+	return h, nil
+}
+
+/*
+Page returns a copy of the keys and values of the page
+*/
+func (h *PageHandle) Page() (Page, error) {
+	err := h.check("Page")
+	if err != nil {
+		return Page{}, err
+	}
+	return h.bm.Page(h.pageID)
+}
+
+/*
+SetPage replaces the keys and values of the page and marks it dirty
+*/
+func (h *PageHandle) SetPage(page Page) error {
+	err := h.check("SetPage")
+	if err != nil {
+		return err
+	}
+	err = h.bm.SetPage(h.pageID, page)
+	if err != nil {
+		return err
+	}
+	h.dirty = true
+	return nil
+}
+
+/*
+MarkDirty makes Release write the page back, e.g. after it has been modified through the Pages of the BufferManager.
+It has no effect after Release.
+*/
+func (h *PageHandle) MarkDirty() {
+	if h.check("MarkDirty") != nil {
+		return
+	}
+	h.dirty = true
+}
+
+/*
+Release unpins the page, the handle must not be used afterwards
+*/
+func (h *PageHandle) Release() error {
+	if h.released {
+		if debugHandles {
+			panic(fmt.Sprintf("page %d of %s has been released twice", h.key.Page, h.key.File))
+		}
+		return ErrHandleReleased
+	}
+	h.released = true
+	h.bm.mu.Lock()
+	defer h.bm.mu.Unlock()
+	if debugHandles {
+		delete(h.bm.handles, h)
+	}
+	return h.bm.unpin(h.pageID, h.dirty)
+}
+
+/*
+check fails with ErrHandleReleased if the handle is used after it has been released, debug builds panic instead
+*/
+func (h *PageHandle) check(method string) error {
+	if !h.released {
+		return nil
+	}
+	if debugHandles {
+		panic(fmt.Sprintf("%s called on page %d of %s after it has been released", method, h.key.Page, h.key.File))
+	}
+	return fmt.Errorf("%s called on page %d of %s: %w", method, h.key.Page, h.key.File, ErrHandleReleased)
+}
+
+/*
+leakedPins reports the pages that are still pinned, in debug builds together with the places their handles have been pinned at
+*/
+func (bm *BufferManager) leakedPins() error {
+	var leaks []string
+	for key, pageID := range bm.PageMap {
+		if bm.frames[pageID].pinCount > 0 {
+			leaks = append(leaks, fmt.Sprintf("page %d of %s (%d pins)", key.Page, key.File, bm.frames[pageID].pinCount))
+		}
+	}
+	for h := range bm.handles {
+		leaks = append(leaks, fmt.Sprintf("handle of page %d of %s pinned at %s", h.key.Page, h.key.File, h.pinnedAt))
+	}
+	if len(leaks) == 0 {
+		return nil
+	}
+	sort.Strings(leaks)
+	return fmt.Errorf("%w: %s", ErrLeakedPins, strings.Join(leaks, ", "))
+}
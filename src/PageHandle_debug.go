@@ -0,0 +1,8 @@
+//go:build dmdsdebug
+
+package src
+
+/*
+debugHandles enables the checks of PageHandle, see PageHandle.go
+*/
+const debugHandles = true
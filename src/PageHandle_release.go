@@ -0,0 +1,8 @@
+//go:build !dmdsdebug
+
+package src
+
+/*
+debugHandles disables the checks of PageHandle that cost time, see PageHandle.go
+*/
+const debugHandles = false
@@ -0,0 +1,152 @@
+package src
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+/*
+panics reports if f panics
+*/
+func panics(f func()) (panicked bool) {
+	defer func() {
+		panicked = recover() != nil
+	}()
+	f()
+	return false
+}
+
+/*
+TestPageHandleWritesBackDirtyPages tests that a page modified through its handle is written back after Release
+*/
+func TestPageHandleWritesBackDirtyPages(t *testing.T) {
+	storage := NewMemoryStorage()
+	createTreeInStorage(t, storage, "testFileForHandles")
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024), WithStorage(storage))
+
+	handle, err := myBuffer.PinHandle("testFileForHandles", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err := handle.Page()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Keys[0] != 1 || page.Values[0] != 2 {
+		t.Fatalf("handle returned keys %v and values %v", page.Keys, page.Values)
+	}
+	page.Values[0] = 3
+	err = handle.SetPage(page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = handle.Release()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pinned := myBuffer.Stats().PinnedPages; pinned != 0 {
+		t.Fatalf("%d pages are still pinned after Release", pinned)
+	}
+
+	// a page modified through the Pages of the BufferManager is written back with MarkDirty
+	handle, _ = myBuffer.PinHandle("testFileForHandles", 2)
+	myBuffer.Pages[handle.pageID].Values[0] = 13
+	handle.MarkDirty()
+	_ = handle.Release()
+
+	err = myBuffer.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dat, _ := readStorageFile(storage, "testFileForHandles")
+	if decodePage(dat[offset(1):]).Values[0] != 3 || decodePage(dat[offset(2):]).Values[0] != 13 {
+		t.Error("the modifications have not been written back")
+	}
+	err = myBuffer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+/*
+TestPageHandleDoubleRelease tests that a second Release does not unpin the page again
+*/
+func TestPageHandleDoubleRelease(t *testing.T) {
+	storage := NewMemoryStorage()
+	createTreeInStorage(t, storage, "testFileForDoubleRelease")
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024), WithStorage(storage))
+	first, _ := myBuffer.PinHandle("testFileForDoubleRelease", 1)
+	second, _ := myBuffer.PinHandle("testFileForDoubleRelease", 1)
+
+	_ = first.Release()
+	var err error
+	panicked := panics(func() { err = first.Release() })
+	if debugHandles && !panicked {
+		t.Error("a second Release did not panic in a debug build")
+	}
+	if !debugHandles && !errors.Is(err, ErrHandleReleased) {
+		t.Errorf("a second Release returned %v", err)
+	}
+	if pinned := myBuffer.Stats().PinnedPages; pinned != 1 {
+		t.Errorf("%d pages are pinned instead of 1 after releasing one of two handles twice", pinned)
+	}
+	_ = second.Release()
+}
+
+/*
+TestPageHandleUseAfterRelease tests that the use of a released handle is reported, debug builds panic
+*/
+func TestPageHandleUseAfterRelease(t *testing.T) {
+	storage := NewMemoryStorage()
+	createTreeInStorage(t, storage, "testFileForUseAfterRelease")
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024), WithStorage(storage))
+	handle, _ := myBuffer.PinHandle("testFileForUseAfterRelease", 1)
+	_ = handle.Release()
+
+	var pageErr, setErr error
+	if panics(func() { _, pageErr = handle.Page() }) != debugHandles {
+		t.Errorf("Page after Release panicked only in one of the builds, debug build %v", debugHandles)
+	}
+	if panics(func() { setErr = handle.SetPage(Page{}) }) != debugHandles {
+		t.Errorf("SetPage after Release panicked only in one of the builds, debug build %v", debugHandles)
+	}
+	if panics(handle.MarkDirty) != debugHandles {
+		t.Errorf("MarkDirty after Release panicked only in one of the builds, debug build %v", debugHandles)
+	}
+	if !debugHandles && (!errors.Is(pageErr, ErrHandleReleased) || !errors.Is(setErr, ErrHandleReleased)) {
+		t.Errorf("Page returned %v and SetPage returned %v after Release", pageErr, setErr)
+	}
+}
+
+/*
+TestPageHandleLeakReport tests that Close reports the pages that are still pinned
+*/
+func TestPageHandleLeakReport(t *testing.T) {
+	storage := NewMemoryStorage()
+	createTreeInStorage(t, storage, "testFileForLeaks")
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024), WithStorage(storage))
+	var myLoader = Loader{}
+	tree, err := myLoader.Load("testFileForLeaks", myBuffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = tree.Get(11)
+	_ = tree.Push(3, 4)
+	_, _ = tree.Get(5)
+	_, err = myBuffer.PinHandle("testFileForLeaks", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = myBuffer.Close()
+	if !errors.Is(err, ErrLeakedPins) {
+		t.Fatalf("Close returned %v instead of reporting the leaked pin", err)
+	}
+	if !strings.Contains(err.Error(), "page 2 of testFileForLeaks (1 pins)") || strings.Contains(err.Error(), "page 1 ") {
+		t.Errorf("Close reported %v", err)
+	}
+	if debugHandles && !strings.Contains(err.Error(), "PageHandle_test.go") {
+		t.Errorf("Close did not report where the handle has been pinned: %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package src
+
+/*
+WithPrefetchDepth makes range scans read the next depth leaves ahead while they walk the tree, the default 0 disables it
+*/
+func WithPrefetchDepth(depth int) Option {
+	return func(bm *BufferManager) {
+		bm.prefetchDepth = depth
+	}
+}
+
+/*
+Prefetch reads the given pages of the file into the buffer in the background, so a later Pin does not have to wait for the disk.
+Pages that are already in the buffer or being read are skipped. The pages are not pinned and are evicted before any
+other page as long as they have not been used. Errors are not reported, the Pin of the page reports them.
+*/
+func (bm *BufferManager) Prefetch(fileID string, pages ...uint64) {
+	for _, pageInFile := range pages {
+		bm.prefetching.Add(1)
+		go bm.prefetch(PageKey{File: fileID, Page: pageInFile}, nil)
+	}
+}
+
+/*
+prefetch reads the page of key into the buffer unless it is already there, into a frame of the ring if one is given
+*/
+func (bm *BufferManager) prefetch(key PageKey, ring *ScanRing) {
+	defer bm.prefetching.Done()
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	if _, ok := bm.PageMap[key]; ok {
+		return
+	}
+	if _, ok := bm.loads[key]; ok {
+		return
+	}
+
+	pageID, err := bm.load(key, ring)
+	if err != nil {
+		return
+	}
+	bm.stats.Prefetches++
+	bm.stats.BytesRead += PageSize
+	bm.frames[pageID].prefetched = true
+	// load leaves the page pinned
+	_ = bm.unpin(pageID, false)
+}
+
+/*
+prefetchVictim returns an unpinned frame with a page that has been read ahead but not used
+*/
+func (bm *BufferManager) prefetchVictim() (uint64, bool) {
+	for i := range bm.frames {
+		if bm.frames[i].prefetched && bm.frames[i].pinCount == 0 && !bm.frames[i].loading {
+			return uint64(i), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,118 @@
+package src
+
+import (
+	"os"
+	"testing"
+)
+
+/*
+TestPrefetchReadsAhead tests that prefetched pages are in the buffer unpinned and count as hits once they are pinned
+*/
+func TestPrefetchReadsAhead(t *testing.T) {
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(8*PageSize))
+	createFileWithPages(t, "testFileForPrefetch", 10)
+	defer func() {
+		_ = os.Remove("./testFileForPrefetch")
+	}()
+	defer func() {
+		_ = myBuffer.Close()
+	}()
+
+	myBuffer.Prefetch("testFileForPrefetch", 1, 2, 3, 42)
+	myBuffer.prefetching.Wait()
+	stats := myBuffer.Stats()
+	if stats.Prefetches != 3 || stats.Misses != 0 || stats.PinnedPages != 0 {
+		t.Fatalf("stats are %+v after prefetching 3 pages", stats)
+	}
+
+	pageID, err := myBuffer.Pin("testFileForPrefetch", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if myBuffer.Pages[pageID].Keys[0] != 3 {
+		t.Fatalf("prefetched page holds key %d instead of 3", myBuffer.Pages[pageID].Keys[0])
+	}
+	if myBuffer.frames[pageID].prefetched {
+		t.Fatal("used page is still marked as prefetched")
+	}
+	_ = myBuffer.Unpin(pageID, false)
+	stats = myBuffer.Stats()
+	if stats.Hits != 1 || stats.PrefetchHits != 1 {
+		t.Fatalf("pin of a prefetched page counted %d hits and %d prefetch hits instead of 1", stats.Hits, stats.PrefetchHits)
+	}
+}
+
+/*
+TestPrefetchEvictedFirst tests that unused prefetched pages are evicted before the pages that have been used
+*/
+func TestPrefetchEvictedFirst(t *testing.T) {
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(4*PageSize))
+	createFileWithPages(t, "testFileForPrefetchEviction", 6)
+	defer func() {
+		_ = os.Remove("./testFileForPrefetchEviction")
+	}()
+	defer func() {
+		_ = myBuffer.Close()
+	}()
+
+	myBuffer.Prefetch("testFileForPrefetchEviction", 0, 1)
+	myBuffer.prefetching.Wait()
+	for pageInFile := uint64(2); pageInFile < 4; pageInFile++ {
+		pageID, _ := myBuffer.Pin("testFileForPrefetchEviction", pageInFile)
+		_ = myBuffer.Unpin(pageID, false)
+	}
+
+	for pageInFile := uint64(4); pageInFile < 6; pageInFile++ {
+		pageID, _ := myBuffer.Pin("testFileForPrefetchEviction", pageInFile)
+		_ = myBuffer.Unpin(pageID, false)
+	}
+	for pageInFile := uint64(2); pageInFile < 4; pageInFile++ {
+		if _, ok := myBuffer.PageMap[PageKey{File: "testFileForPrefetchEviction", Page: pageInFile}]; !ok {
+			t.Fatalf("used page %d has been evicted before the prefetched pages", pageInFile)
+		}
+	}
+}
+
+/*
+TestPrefetchRangeScan tests that a range scan with read ahead returns every pair and leaves no page pinned
+*/
+func TestPrefetchRangeScan(t *testing.T) {
+	storage := NewMemoryStorage()
+	file, _ := storage.Open("testFileForPrefetchScan", true)
+	_, _ = file.WriteAt([]byte("10;20;30;40;;;1;2;3;4;5;;\n5;10;;;;;6;11;;;;;\n15;20;;;;;16;21;;;;;\n25;30;;;;;26;31;;;;;\n35;40;;;;;36;41;;;;;\n45;50;;;;;46;51;;;;;"), 0)
+	_ = file.Close()
+	myConverter := Converter{Storage: storage}
+	_ = myConverter.TextToBinary("testFileForPrefetchScan", "testFileForPrefetchScan")
+
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(8*PageSize), WithStorage(storage), WithPrefetchDepth(2))
+	var myLoader = Loader{}
+	tree, err := myLoader.Load("testFileForPrefetchScan", myBuffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := tree.GetRange(10, 45)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[uint64]uint64{10: 11, 15: 16, 20: 21, 25: 26, 30: 31, 35: 36, 40: 41, 45: 46}
+	if len(result) != len(expected) {
+		t.Fatalf("GetRange returned %v instead of %v", result, expected)
+	}
+	for k, v := range expected {
+		if result[k] != v {
+			t.Fatalf("GetRange returned %v instead of %v", result, expected)
+		}
+	}
+
+	err = myBuffer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pinned := myBuffer.Stats().PinnedPages; pinned != 0 {
+		t.Fatalf("%d pages are still pinned after the scan", pinned)
+	}
+	_, err = CreateNewBufferManager("./", uint64(1024), WithPrefetchDepth(-1))
+	if err == nil {
+		t.Fatal("negative prefetch depth should return an error but does not")
+	}
+}
@@ -0,0 +1,83 @@
+package src
+
+import (
+	"container/list"
+	"fmt"
+)
+
+/*
+Replacer decides which frame of the BufferManager is reused when no frame is free.
+The BufferManager reports every pin and whether a frame may currently be evicted,
+pinned frames are never evictable.
+*/
+type Replacer interface {
+	// RecordAccess notes that the frame has been pinned and holds the given page
+	RecordAccess(frameID uint64, page PageKey)
+	// SetEvictable marks if the frame may be chosen as a victim
+	SetEvictable(frameID uint64, evictable bool)
+	// Evict chooses a victim among the evictable frames and stops tracking it, false if there is none
+	Evict() (uint64, bool)
+	// Remove stops tracking the frame without treating it as an eviction, e.g. when its page is freed
+	Remove(frameID uint64)
+}
+
+/*
+ReplacementPolicy selects the Replacer a BufferManager is created with
+*/
+type ReplacementPolicy int
+
+const (
+	PolicyLRU   ReplacementPolicy = iota // evict the least recently used page
+	PolicyClock                          // second chance, approximates LRU with a reference bit
+	PolicyLRUK                           // evict the page with the oldest second last access
+	Policy2Q                             // pages only enter the main queue on their second access
+	PolicyARC                            // balances recency and frequency based on recently evicted pages
+)
+
+func (p ReplacementPolicy) String() string {
+	switch p {
+	case PolicyLRU:
+		return "LRU"
+	case PolicyClock:
+		return "Clock"
+	case PolicyLRUK:
+		return "LRU-K"
+	case Policy2Q:
+		return "2Q"
+	case PolicyARC:
+		return "ARC"
+	}
+	return fmt.Sprintf("ReplacementPolicy(%d)", int(p))
+}
+
+/*
+NewReplacer creates the Replacer for the given policy and a pool with the given amount of frames
+*/
+func NewReplacer(policy ReplacementPolicy, frames int) (Replacer, error) {
+	switch policy {
+	case PolicyLRU:
+		return newLRUReplacer(), nil
+	case PolicyClock:
+		return newClockReplacer(), nil
+	case PolicyLRUK:
+		return newLRUKReplacer(2), nil
+	case Policy2Q:
+		return new2QReplacer(frames), nil
+	case PolicyARC:
+		return newARCReplacer(frames), nil
+	}
+	return nil, fmt.Errorf("unknown replacement policy %v", policy)
+}
+
+/*
+lastEvictable returns the element closest to the back of the list whose frame is evictable, nil if there is none.
+The values of the list have to be frame ids.
+*/
+func lastEvictable(l *list.List, evictable map[uint64]bool) *list.Element {
+	for e := l.Back(); e != nil; e = e.Prev() {
+		if evictable[e.Value.(uint64)] {
+			return e
+		}
+	}
+	return nil
+}
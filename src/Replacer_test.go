@@ -0,0 +1,194 @@
+package src
+
+import (
+	"os"
+	"testing"
+)
+
+var allPolicies = []ReplacementPolicy{PolicyLRU, PolicyClock, PolicyLRUK, Policy2Q, PolicyARC}
+
+/*
+TestReplacerSkipsPinnedFrames tests that no policy evicts a frame that is not evictable
+*/
+func TestReplacerSkipsPinnedFrames(t *testing.T) {
+	for _, policy := range allPolicies {
+		replacer, err := NewReplacer(policy, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for frameID := uint64(0); frameID < 3; frameID++ {
+			replacer.RecordAccess(frameID, PageKey{Page: frameID + 10})
+		}
+		replacer.SetEvictable(1, true)
+
+		victim, ok := replacer.Evict()
+		if !ok || victim != 1 {
+			t.Errorf("%v evicted %d, %v instead of the only evictable frame 1", policy, victim, ok)
+		}
+		if _, ok = replacer.Evict(); ok {
+			t.Errorf("%v evicted a frame although every frame is pinned", policy)
+		}
+
+		replacer.SetEvictable(2, true)
+		replacer.Remove(2)
+		if _, ok = replacer.Evict(); ok {
+			t.Errorf("%v evicted a frame that has been removed", policy)
+		}
+	}
+}
+
+/*
+TestReplacerUnknownPolicy tests that an unknown policy is rejected
+*/
+func TestReplacerUnknownPolicy(t *testing.T) {
+	if _, err := NewReplacer(ReplacementPolicy(42), 10); err == nil {
+		t.Error("NewReplacer should return an error for an unknown policy but does not")
+	}
+	if _, err := CreateNewBufferManager("./", uint64(1024), WithReplacementPolicy(ReplacementPolicy(42))); err == nil {
+		t.Error("CreateNewBufferManager should return an error for an unknown policy but does not")
+	}
+}
+
+/*
+TestLRUReplacerOrder tests that the least recently used frame is evicted first
+*/
+func TestLRUReplacerOrder(t *testing.T) {
+	replacer := newLRUReplacer()
+	for frameID := uint64(0); frameID < 3; frameID++ {
+		replacer.RecordAccess(frameID, PageKey{Page: frameID})
+		replacer.SetEvictable(frameID, true)
+	}
+	replacer.RecordAccess(0, PageKey{Page: 0})
+
+	for _, expected := range []uint64{1, 2, 0} {
+		if victim, _ := replacer.Evict(); victim != expected {
+			t.Fatalf("LRU evicted frame %d instead of %d", victim, expected)
+		}
+	}
+}
+
+/*
+TestClockReplacerSecondChance tests that a referenced frame survives one sweep of the hand
+*/
+func TestClockReplacerSecondChance(t *testing.T) {
+	replacer := newClockReplacer()
+	for frameID := uint64(0); frameID < 3; frameID++ {
+		replacer.RecordAccess(frameID, PageKey{Page: frameID})
+		replacer.SetEvictable(frameID, true)
+	}
+
+	if victim, _ := replacer.Evict(); victim != 0 {
+		t.Fatalf("Clock evicted frame %d instead of 0", victim)
+	}
+	replacer.RecordAccess(1, PageKey{Page: 1})
+	if victim, _ := replacer.Evict(); victim != 2 {
+		t.Fatalf("Clock evicted frame %d instead of 2 which has no reference bit", victim)
+	}
+}
+
+/*
+TestLRUKReplacerPrefersSingleAccess tests that a frame accessed less than k times is evicted before frequent ones
+*/
+func TestLRUKReplacerPrefersSingleAccess(t *testing.T) {
+	replacer := newLRUKReplacer(2)
+	replacer.RecordAccess(0, PageKey{Page: 0})
+	replacer.RecordAccess(0, PageKey{Page: 0})
+	replacer.RecordAccess(1, PageKey{Page: 1})
+	replacer.RecordAccess(2, PageKey{Page: 2})
+	replacer.RecordAccess(2, PageKey{Page: 2})
+	for frameID := uint64(0); frameID < 3; frameID++ {
+		replacer.SetEvictable(frameID, true)
+	}
+
+	for _, expected := range []uint64{1, 0, 2} {
+		if victim, _ := replacer.Evict(); victim != expected {
+			t.Fatalf("LRU-K evicted frame %d instead of %d", victim, expected)
+		}
+	}
+}
+
+/*
+TestTwoQReplacerPromotesGhosts tests that only a page that comes back after its eviction from a1in enters am
+*/
+func TestTwoQReplacerPromotesGhosts(t *testing.T) {
+	replacer := new2QReplacer(4)
+	replacer.RecordAccess(0, PageKey{Page: 10})
+	replacer.RecordAccess(1, PageKey{Page: 11})
+	replacer.SetEvictable(0, true)
+	replacer.SetEvictable(1, true)
+
+	if victim, _ := replacer.Evict(); victim != 0 {
+		t.Fatalf("2Q evicted frame %d instead of the oldest frame 0 of a1in", victim)
+	}
+	replacer.RecordAccess(0, PageKey{Page: 10})
+	if replacer.queues[0] != replacer.am {
+		t.Fatal("page 10 has not been promoted to am after it came back")
+	}
+	replacer.SetEvictable(0, true)
+	replacer.RecordAccess(2, PageKey{Page: 12})
+	replacer.SetEvictable(2, true)
+
+	if victim, _ := replacer.Evict(); victim != 1 {
+		t.Fatalf("2Q evicted frame %d instead of frame 1 from a1in", victim)
+	}
+}
+
+/*
+TestARCReplacerAdapts tests that a hit on a page recently evicted from t1 grows the target size of t1
+*/
+func TestARCReplacerAdapts(t *testing.T) {
+	replacer := newARCReplacer(2)
+	replacer.RecordAccess(0, PageKey{Page: 10})
+	replacer.RecordAccess(1, PageKey{Page: 11})
+	replacer.SetEvictable(0, true)
+	replacer.SetEvictable(1, true)
+
+	if victim, _ := replacer.Evict(); victim != 0 {
+		t.Fatalf("ARC evicted frame %d instead of the least recently used frame 0 of t1", victim)
+	}
+	replacer.RecordAccess(0, PageKey{Page: 10})
+	if replacer.p != 1 {
+		t.Fatalf("target size of t1 is %d instead of 1 after a hit in b1", replacer.p)
+	}
+	if replacer.lists[0] != replacer.t2 {
+		t.Fatal("page 10 has not been moved to t2 after a hit in b1")
+	}
+}
+
+/*
+TestBufferManagerPolicies runs a mix of hot point lookups and a long scan with every policy
+and checks that the right pages are returned
+*/
+func TestBufferManagerPolicies(t *testing.T) {
+	createFileWithPages(t, "testFileForPolicies", 40)
+	defer func() {
+		_ = os.Remove("./testFileForPolicies")
+	}()
+
+	for _, policy := range allPolicies {
+		myBuffer, err := CreateNewBufferManager("./", uint64(1024), WithReplacementPolicy(policy))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for round := 0; round < 5; round++ {
+			for pageInFile := uint64(3); pageInFile < 40; pageInFile++ {
+				for _, accessed := range []uint64{pageInFile % 3, pageInFile} {
+					id, err := myBuffer.Pin("testFileForPolicies", accessed)
+					if err != nil {
+						t.Fatalf("%v: error while pinning page %d: %v", policy, accessed, err)
+					}
+					if myBuffer.Pages[id].Keys[0] != accessed+1 {
+						t.Fatalf("%v: frame %d holds key %d instead of %d", policy, id, myBuffer.Pages[id].Keys[0], accessed+1)
+					}
+					_ = myBuffer.Unpin(id, false)
+				}
+			}
+		}
+		t.Logf("%v hit ratio: %.2f", policy, myBuffer.HitRatio())
+		if myBuffer.HitRatio() == 0 {
+			t.Errorf("%v did not keep any of the hot pages", policy)
+		}
+		_ = myBuffer.Close()
+	}
+}
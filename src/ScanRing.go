@@ -0,0 +1,96 @@
+package src
+
+import (
+	"fmt"
+)
+
+/*
+ScanRing is an access strategy for large scans. Pages that are not in the buffer are read into a small
+ring of frames that is recycled as the scan goes on, so a scan does not push the hot pages out of the pool.
+Pages that are already in the buffer are used where they are. A ring belongs to one scan and must not be
+used by several goroutines at the same time, the pages it pins are unpinned with Unpin of the BufferManager.
+Its read aheads run in the background but only touch the ring while they hold the latch of the page table.
+*/
+type ScanRing struct {
+	bm    *BufferManager
+	size  int
+	slots []ringSlot
+	next  int // slot that is recycled next
+}
+
+/*
+ringSlot is a frame of the ring and the page that has been read into it
+*/
+type ringSlot struct {
+	pageID uint64
+	key    PageKey
+}
+
+/*
+NewScanRing creates a ring of size frames for a scan
+*/
+func (bm *BufferManager) NewScanRing(size int) (*ScanRing, error) {
+	if size < 1 {
+		return nil, fmt.Errorf("a scan ring needs at least one frame, got %d", size)
+	}
+	return &ScanRing{bm: bm, size: size}, nil
+}
+
+/*
+Pin pins the page like Pin of the BufferManager, a page that is not in the buffer is read into a frame of the ring
+*/
+func (r *ScanRing) Pin(fileID string, pageInFile uint64) (uint64, error) {
+	r.bm.mu.Lock()
+	defer r.bm.mu.Unlock()
+	pageID, waited, err := r.bm.pin(PageKey{File: fileID, Page: pageInFile}, r)
+	r.bm.stats.PinWait.observe(waited)
+	return pageID, err
+}
+
+/*
+Prefetch reads the given pages in the background like Prefetch of the BufferManager, but into frames of the ring.
+A read ahead that has not been used yet is recycled like any other page of the ring, so the ring has to be larger
+than the number of pages that are read ahead for them to survive until the scan reaches them.
+*/
+func (r *ScanRing) Prefetch(fileID string, pages ...uint64) {
+	for _, pageInFile := range pages {
+		r.bm.prefetching.Add(1)
+		go r.bm.prefetch(PageKey{File: fileID, Page: pageInFile}, r)
+	}
+}
+
+/*
+frame returns the frame the next page of the scan is read into. Once the ring is full its frames are recycled in turn,
+a frame whose page is pinned or that has been taken over by another page is replaced by a frame of the pool.
+The latch of the page table has to be held.
+*/
+func (r *ScanRing) frame() (uint64, error) {
+	if len(r.slots) < r.size {
+		return r.bm.freeFrame()
+	}
+	slot := r.slots[r.next]
+	if pageID, ok := r.bm.PageMap[slot.key]; !ok || pageID != slot.pageID || r.bm.frames[pageID].pinCount > 0 {
+		return r.bm.freeFrame()
+	}
+	if r.bm.frames[slot.pageID].dirty {
+		err := r.bm.serialize(slot.pageID)
+		if err != nil {
+			return 0, err
+		}
+	}
+	r.bm.dropFrame(slot.pageID)
+	r.bm.stats.Evictions++
+	return slot.pageID, nil
+}
+
+/*
+record adds the frame the page has been read into to the ring
+*/
+func (r *ScanRing) record(pageID uint64, key PageKey) {
+	if len(r.slots) < r.size {
+		r.slots = append(r.slots, ringSlot{pageID: pageID, key: key})
+		return
+	}
+	r.slots[r.next] = ringSlot{pageID: pageID, key: key}
+	r.next = (r.next + 1) % r.size
+}
@@ -0,0 +1,136 @@
+package src
+
+import (
+	"os"
+	"testing"
+)
+
+/*
+TestScanRingKeepsHotPages tests that a scan over more pages than the pool holds only recycles the frames of its ring
+*/
+func TestScanRingKeepsHotPages(t *testing.T) {
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(8*PageSize))
+	createFileWithPages(t, "testFileForScan", 40)
+	defer func() {
+		_ = os.Remove("./testFileForScan")
+	}()
+	defer func() {
+		_ = myBuffer.Close()
+	}()
+
+	for pageInFile := uint64(0); pageInFile < 4; pageInFile++ {
+		pageID, _ := myBuffer.Pin("testFileForScan", pageInFile)
+		_ = myBuffer.Unpin(pageID, false)
+	}
+
+	ring, err := myBuffer.NewScanRing(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ringFrames := make(map[uint64]bool)
+	for pageInFile := uint64(0); pageInFile < 40; pageInFile++ {
+		pageID, err := ring.Pin("testFileForScan", pageInFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		page, _ := myBuffer.Page(pageID)
+		if page.Keys[0] != pageInFile+1 {
+			t.Fatalf("scan read key %d instead of %d", page.Keys[0], pageInFile+1)
+		}
+		_ = myBuffer.Unpin(pageID, false)
+		if pageInFile >= 4 {
+			ringFrames[pageID] = true
+		}
+	}
+
+	if len(ringFrames) != 2 {
+		t.Fatalf("scan used %d frames instead of the 2 frames of its ring", len(ringFrames))
+	}
+	for pageInFile := uint64(0); pageInFile < 4; pageInFile++ {
+		if _, ok := myBuffer.PageMap[PageKey{File: "testFileForScan", Page: pageInFile}]; !ok {
+			t.Fatalf("hot page %d has been evicted by the scan", pageInFile)
+		}
+	}
+}
+
+/*
+TestScanRingWithPinnedFrame tests that a frame of the ring whose page is still pinned is not recycled
+*/
+func TestScanRingWithPinnedFrame(t *testing.T) {
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(8*PageSize))
+	createFileWithPages(t, "testFileForPinnedScan", 4)
+	defer func() {
+		_ = os.Remove("./testFileForPinnedScan")
+	}()
+	defer func() {
+		_ = myBuffer.Close()
+	}()
+
+	_, err := myBuffer.NewScanRing(0)
+	if err == nil {
+		t.Fatal("a ring without frames should return an error but does not")
+	}
+	ring, _ := myBuffer.NewScanRing(1)
+	first, _ := ring.Pin("testFileForPinnedScan", 0)
+	second, err := ring.Pin("testFileForPinnedScan", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Fatal("pinned page has been replaced by the scan")
+	}
+	page, _ := myBuffer.Page(first)
+	if page.Keys[0] != 1 {
+		t.Fatalf("pinned page holds key %d instead of 1", page.Keys[0])
+	}
+	_ = myBuffer.Unpin(first, false)
+	_ = myBuffer.Unpin(second, false)
+
+	// the ring continues with the frame it has taken instead
+	third, _ := ring.Pin("testFileForPinnedScan", 2)
+	if third != second {
+		t.Fatalf("scan read into frame %d instead of recycling frame %d", third, second)
+	}
+	_ = myBuffer.Unpin(third, false)
+}
+
+/*
+TestScanRingPrefetch tests that pages read ahead for a scan go into the frames of its ring instead of the pool
+*/
+func TestScanRingPrefetch(t *testing.T) {
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(8*PageSize))
+	createFileWithPages(t, "testFileForPrefetchRing", 40)
+	defer func() {
+		_ = os.Remove("./testFileForPrefetchRing")
+	}()
+	defer func() {
+		_ = myBuffer.Close()
+	}()
+
+	ring, _ := myBuffer.NewScanRing(3)
+	ring.Prefetch("testFileForPrefetchRing", 0)
+	for pageInFile := uint64(0); pageInFile < 40; pageInFile++ {
+		// the page is read ahead while the previous one is used
+		myBuffer.prefetching.Wait()
+		if pageInFile+1 < 40 {
+			ring.Prefetch("testFileForPrefetchRing", pageInFile+1)
+		}
+		pageID, err := ring.Pin("testFileForPrefetchRing", pageInFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		page, _ := myBuffer.Page(pageID)
+		if page.Keys[0] != pageInFile+1 {
+			t.Fatalf("scan read key %d instead of %d", page.Keys[0], pageInFile+1)
+		}
+		_ = myBuffer.Unpin(pageID, false)
+	}
+	myBuffer.prefetching.Wait()
+
+	if stats := myBuffer.Stats(); stats.PrefetchHits == 0 {
+		t.Fatal("no page that has been read ahead has been used by the scan")
+	}
+	if len(myBuffer.PageMap) > 3 {
+		t.Fatalf("the scan has left %d pages in the buffer instead of at most the 3 frames of its ring", len(myBuffer.PageMap))
+	}
+}
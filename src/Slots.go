@@ -0,0 +1,422 @@
+package src
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"sort"
+)
+
+/*
+Compressed and encrypted files keep the header in the first PageSize bytes like every binary file. Behind it every
+page is stored in a slot of its own size, compressed (see Compression.go) and encrypted (see Encryption.go).
+The slot table lists the offset and size of the slot of every page and is stored in the file as well, the header points to it.
+
+Slots and the table the header points to are never overwritten. A batch of pages is written into space that is
+not referenced, followed by a new table, and committed by writing the header through the double write buffer.
+A crash before the header is in place leaves the previous table and its slots untouched.
+
+The table holds the number of pages, the offset and size of every slot and the CRC32C checksum of everything before it.
+*/
+const slotEntrySize = 16
+
+/*
+slot is a range of bytes in a file with slots
+*/
+type slot struct {
+	offset int64
+	size   int64
+}
+
+/*
+end returns the offset right behind the slot
+*/
+func (s slot) end() int64 {
+	return s.offset + s.size
+}
+
+/*
+slotTable is the committed slot table of a file
+*/
+type slotTable struct {
+	slots   []slot     // slot of every page, the index is pageInFile
+	table   slot       // where the table itself is stored
+	encoded []byte     // content of the table
+	header  FileHeader // the header the table has been committed with
+	space   *freeSpace // space that is not referenced by the table, kept up to date by writeSlots
+}
+
+/*
+slotChange is a page that has been written into a new slot and is not committed yet
+*/
+type slotChange struct {
+	pageInFile uint64
+	place      slot
+}
+
+/*
+initSlots prepares a file whose pages are stored in slots. buf holds the header of an existing file,
+it is nil for a new file which gets the compression and encryption of the BufferManager.
+*/
+func (bm *BufferManager) initSlots(f *binaryPageFile, buf []byte) error {
+	var err error
+	f.slotted = true
+	if buf == nil {
+		f.header.Compressed = bm.compress
+		f.header.Encrypted = bm.encryptionKey != nil
+		f.compress = f.header.Compressed
+		if f.header.Encrypted {
+			f.cipher, err = createPageCipher(bm.encryptionKey)
+			if err != nil {
+				return err
+			}
+		}
+		f.slots = &slotTable{header: f.header, space: &freeSpace{end: PageSize}}
+		return nil
+	}
+
+	f.compress = f.header.Compressed
+	if f.header.Encrypted {
+		if bm.encryptionKey == nil {
+			return fmt.Errorf("%s is encrypted, the key has to be given with WithEncryptionKey", f.name)
+		}
+		f.cipher, err = loadPageCipher(f.name, bm.encryptionKey, buf)
+		if err != nil {
+			return err
+		}
+	}
+	f.slots, err = loadSlotTable(f.name, f.file, buf)
+	if err != nil {
+		return err
+	}
+	f.slots.header = f.header
+	return nil
+}
+
+/*
+readSlot reads the slot of the page and returns the encoded page
+*/
+func (f *binaryPageFile) readSlot(pageInFile uint64) ([]byte, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if pageInFile >= uint64(len(f.slots.slots)) {
+		return nil, fmt.Errorf("page %d is not part of %s", pageInFile, f.name)
+	}
+	place := f.slots.slots[pageInFile]
+	data := make([]byte, place.size)
+	_, err := f.file.ReadAt(data, place.offset)
+	if err == io.EOF {
+		return nil, &CorruptionError{File: f.name, Page: pageInFile, Reason: "the slot is truncated"}
+	}
+	if err != nil {
+		return nil, err
+	}
+	buf, err := f.decodeSlot(pageInFile, data)
+	if err != nil {
+		return nil, &CorruptionError{File: f.name, Page: pageInFile, Reason: err.Error()}
+	}
+	return buf, nil
+}
+
+/*
+encodeSlot turns an encoded page into the content of its slot
+*/
+func (f *binaryPageFile) encodeSlot(pageInFile uint64, buf []byte) ([]byte, error) {
+	data := buf
+	if f.compress {
+		var err error
+		data, err = compressPage(buf)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if f.cipher != nil {
+		data = f.cipher.seal(pageInFile, data)
+	}
+	return data, nil
+}
+
+/*
+decodeSlot restores the encoded page out of the content of its slot and verifies its checksum
+*/
+func (f *binaryPageFile) decodeSlot(pageInFile uint64, data []byte) ([]byte, error) {
+	var err error
+	if f.cipher != nil {
+		data, err = f.cipher.open(pageInFile, data)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if f.compress {
+		data, err = decompressPage(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(data) != PageSize {
+		return nil, fmt.Errorf("the slot holds %d bytes instead of a page", len(data))
+	}
+	if !validChecksum(data) {
+		return nil, errors.New("checksum mismatch")
+	}
+	return data, nil
+}
+
+/*
+writeSlots writes the page images of the records into free space of the file and commits them with a new table and header.
+The record of the header is always written, its content is taken from f.header.
+*/
+func (f *binaryPageFile) writeSlots(records []doubleWriteRecord) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.cipher != nil {
+		err := f.reserveVersions(uint64(len(records)))
+		if err != nil {
+			return err
+		}
+	}
+	// the space is only given back once the table is committed, a failed batch leaves the index as it is
+	space := f.slots.space.clone()
+	count := uint64(len(f.slots.slots))
+	changes := make([]slotChange, 0, len(records))
+	for _, record := range records {
+		if record.offset == 0 {
+			continue
+		}
+		pageInFile := uint64(record.offset/PageSize - 1)
+		if pageInFile > count {
+			return fmt.Errorf("page %d is not part of %s", pageInFile, f.name)
+		}
+		data, err := f.encodeSlot(pageInFile, record.buf)
+		if err != nil {
+			return err
+		}
+		place := space.take(int64(len(data)))
+		_, err = f.file.WriteAt(data, place.offset)
+		if err != nil {
+			return err
+		}
+		if pageInFile == count {
+			count++
+		}
+		changes = append(changes, slotChange{pageInFile: pageInFile, place: place})
+	}
+	table := f.slots.encodeChanges(count, changes)
+	tablePlace := space.take(int64(len(table)))
+	_, err := f.file.WriteAt(table, tablePlace.offset)
+	if err != nil {
+		return err
+	}
+	if f.sync {
+		// the slots have to be on disk before the header points to them
+		err = f.file.Sync()
+		if err != nil {
+			return err
+		}
+	}
+
+	err = f.writeInPlace([]doubleWriteRecord{{offset: 0, buf: f.slottedHeader(f.header, tablePlace)}}, f.sync)
+	if err != nil {
+		return err
+	}
+	// the slots and the table that have been replaced are free from now on
+	space.release(f.slots.table)
+	for _, change := range changes {
+		if change.pageInFile < uint64(len(f.slots.slots)) {
+			space.release(f.slots.slots[change.pageInFile])
+			f.slots.slots[change.pageInFile] = change.place
+		} else {
+			f.slots.slots = append(f.slots.slots, change.place)
+		}
+	}
+	f.slots.table = tablePlace
+	f.slots.encoded = table
+	f.slots.header = f.header
+	f.slots.space = space
+
+	// nothing behind the last slot is referenced anymore, a failed truncate only leaves space that is reused later
+	_ = f.file.Truncate(space.end)
+	return nil
+}
+
+/*
+loadSlotTable reads the table the header in buf points to
+*/
+func loadSlotTable(fileID string, file io.ReaderAt, buf []byte) (*slotTable, error) {
+	place := slot{
+		offset: int64(binary.LittleEndian.Uint64(buf[headerTableOffset:])),
+		size:   int64(binary.LittleEndian.Uint64(buf[headerTableSizeOffset:])),
+	}
+	if place.offset < PageSize || place.size < 8+4 || (place.size-8-4)%slotEntrySize != 0 {
+		return nil, &CorruptionError{File: fileID, Page: noPage, Reason: "the slot table is out of place"}
+	}
+	table := make([]byte, place.size)
+	_, err := file.ReadAt(table, place.offset)
+	if err == io.EOF {
+		return nil, &CorruptionError{File: fileID, Page: noPage, Reason: "the slot table is truncated"}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if binary.LittleEndian.Uint32(table[len(table)-4:]) != crc32.Checksum(table[:len(table)-4], castagnoli) {
+		return nil, &CorruptionError{File: fileID, Page: noPage, Reason: "slot table checksum mismatch"}
+	}
+	count := binary.LittleEndian.Uint64(table)
+	if count != uint64(place.size-8-4)/slotEntrySize {
+		return nil, &CorruptionError{File: fileID, Page: noPage, Reason: "the slot table has the wrong size"}
+	}
+	slots := make([]slot, count)
+	for i := range slots {
+		start := 8 + i*slotEntrySize
+		slots[i] = slot{
+			offset: int64(binary.LittleEndian.Uint64(table[start:])),
+			size:   int64(binary.LittleEndian.Uint64(table[start+8:])),
+		}
+		if slots[i].offset < PageSize || slots[i].size <= 0 {
+			return nil, &CorruptionError{File: fileID, Page: uint64(i), Reason: "the slot is out of place"}
+		}
+	}
+	t := &slotTable{slots: slots, table: place, encoded: table}
+	t.space = t.freeSpace()
+	return t, nil
+}
+
+/*
+slottedHeader encodes the header together with the place of the slot table and the parameters of the encryption
+*/
+func (f *binaryPageFile) slottedHeader(header FileHeader, table slot) []byte {
+	buf := make([]byte, PageSize)
+	encodeHeader(header, buf)
+	if f.cipher != nil {
+		f.cipher.encodeParams(buf)
+	}
+	setTableLocation(buf, table)
+	return buf
+}
+
+/*
+setTableLocation stores the place of the slot table in the encoded header in buf and renews its checksum
+*/
+func setTableLocation(buf []byte, place slot) {
+	binary.LittleEndian.PutUint64(buf[headerTableOffset:], uint64(place.offset))
+	binary.LittleEndian.PutUint64(buf[headerTableSizeOffset:], uint64(place.size))
+	setChecksum(buf)
+}
+
+/*
+encodeSlotTable returns the content of the table for the slots
+*/
+func encodeSlotTable(slots []slot) []byte {
+	table := make([]byte, 8+len(slots)*slotEntrySize+4)
+	binary.LittleEndian.PutUint64(table, uint64(len(slots)))
+	for i, place := range slots {
+		putSlotEntry(table, uint64(i), place)
+	}
+	binary.LittleEndian.PutUint32(table[len(table)-4:], crc32.Checksum(table[:len(table)-4], castagnoli))
+	return table
+}
+
+/*
+encodeChanges returns the content of the table for count pages with the changes applied, the other entries are copied
+*/
+func (t *slotTable) encodeChanges(count uint64, changes []slotChange) []byte {
+	table := make([]byte, 8+count*slotEntrySize+4)
+	if len(t.encoded) > 0 {
+		copy(table, t.encoded[:len(t.encoded)-4])
+	}
+	binary.LittleEndian.PutUint64(table, count)
+	for _, change := range changes {
+		putSlotEntry(table, change.pageInFile, change.place)
+	}
+	binary.LittleEndian.PutUint32(table[len(table)-4:], crc32.Checksum(table[:len(table)-4], castagnoli))
+	return table
+}
+
+/*
+putSlotEntry stores the slot of the page in the encoded table
+*/
+func putSlotEntry(table []byte, pageInFile uint64, place slot) {
+	start := 8 + pageInFile*slotEntrySize
+	binary.LittleEndian.PutUint64(table[start:], uint64(place.offset))
+	binary.LittleEndian.PutUint64(table[start+8:], uint64(place.size))
+}
+
+/*
+freeSpace is the space of a file with slots that is not referenced by the committed table
+*/
+type freeSpace struct {
+	gaps []slot // unused ranges between referenced slots, ordered by offset
+	end  int64  // everything from here on is unused
+}
+
+/*
+freeSpace works out the space that can be written without touching the committed table or its slots
+*/
+func (t *slotTable) freeSpace() *freeSpace {
+	used := append([]slot{t.table}, t.slots...)
+	sort.Slice(used, func(i, j int) bool { return used[i].offset < used[j].offset })
+	space := &freeSpace{end: PageSize}
+	for _, place := range used {
+		if place.offset > space.end {
+			space.gaps = append(space.gaps, slot{offset: space.end, size: place.offset - space.end})
+		}
+		if place.end() > space.end {
+			space.end = place.end()
+		}
+	}
+	return space
+}
+
+/*
+clone returns a copy that can be taken from without changing s
+*/
+func (s *freeSpace) clone() *freeSpace {
+	return &freeSpace{gaps: append([]slot(nil), s.gaps...), end: s.end}
+}
+
+/*
+take reserves size bytes in the first gap they fit into or at the end
+*/
+func (s *freeSpace) take(size int64) slot {
+	for i, gap := range s.gaps {
+		if gap.size > size {
+			s.gaps[i] = slot{offset: gap.offset + size, size: gap.size - size}
+			return slot{offset: gap.offset, size: size}
+		}
+		if gap.size == size {
+			s.gaps = append(s.gaps[:i], s.gaps[i+1:]...)
+			return gap
+		}
+	}
+	place := slot{offset: s.end, size: size}
+	s.end += size
+	return place
+}
+
+/*
+release gives the place back, it is merged with the gaps next to it and space at the end shrinks the file
+*/
+func (s *freeSpace) release(place slot) {
+	if place.size == 0 {
+		return
+	}
+	i := sort.Search(len(s.gaps), func(i int) bool { return s.gaps[i].offset > place.offset })
+	if i > 0 && s.gaps[i-1].end() == place.offset {
+		i--
+		place = slot{offset: s.gaps[i].offset, size: s.gaps[i].size + place.size}
+		s.gaps = append(s.gaps[:i], s.gaps[i+1:]...)
+	}
+	if i < len(s.gaps) && place.end() == s.gaps[i].offset {
+		place.size += s.gaps[i].size
+		s.gaps = append(s.gaps[:i], s.gaps[i+1:]...)
+	}
+	if place.end() == s.end {
+		s.end = place.offset
+		return
+	}
+	s.gaps = append(s.gaps, slot{})
+	copy(s.gaps[i+1:], s.gaps[i:])
+	s.gaps[i] = place
+}
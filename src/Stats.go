@@ -0,0 +1,136 @@
+package src
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+/*
+pinWaitBounds are the upper bounds of the buckets of the pin wait histogram in seconds
+*/
+var pinWaitBounds = []float64{0.0001, 0.001, 0.01, 0.1, 1, 10}
+
+/*
+Stats are the counters of a BufferManager since it has been created
+*/
+type Stats struct {
+	Pins         uint64    // successful pins
+	Hits         uint64    // pins that found the page in the buffer
+	Misses       uint64    // pins that had to read the page from disk
+	Evictions    uint64    // pages removed from the buffer to make room for other pages
+	WriteBacks   uint64    // dirty pages written to disk
+	BytesRead    uint64    // bytes of the pages read from disk
+	BytesWritten uint64    // bytes of the pages written to disk
+	Prefetches   uint64    // pages read ahead
+	PrefetchHits uint64    // pins that found a page that has been read ahead
+	Views        uint64    // pages returned by PinView, they are read in place from the mapping of the file
+	PinWait      Histogram // time pins waited for a free frame or for a concurrent read of the page
+	Frames       int       // number of frames in the pool
+	DirtyPages   int       // number of dirty frames right now
+	PinnedPages  int       // number of pinned frames right now
+}
+
+/*
+Histogram counts observations in buckets with fixed upper bounds
+*/
+type Histogram struct {
+	Bounds []float64 // upper bounds of the buckets in seconds
+	Counts []uint64  // observations per bucket, the last one holds the observations above every bound
+	Sum    float64   // sum of all observations in seconds
+	Count  uint64    // number of observations
+}
+
+/*
+newHistogram creates an empty Histogram with the given upper bounds
+*/
+func newHistogram(bounds []float64) Histogram {
+	return Histogram{Bounds: bounds, Counts: make([]uint64, len(bounds)+1)}
+}
+
+/*
+observe adds the duration to the histogram
+*/
+func (h *Histogram) observe(duration time.Duration) {
+	seconds := duration.Seconds()
+	bucket := len(h.Bounds)
+	for i, bound := range h.Bounds {
+		if seconds <= bound {
+			bucket = i
+			break
+		}
+	}
+	h.Counts[bucket]++
+	h.Sum += seconds
+	h.Count++
+}
+
+/*
+Stats returns a snapshot of the counters of the BufferManager
+*/
+func (bm *BufferManager) Stats() Stats {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	stats := bm.stats
+	stats.PinWait.Bounds = append([]float64(nil), bm.stats.PinWait.Bounds...)
+	stats.PinWait.Counts = append([]uint64(nil), bm.stats.PinWait.Counts...)
+	stats.Frames = len(bm.frames)
+	stats.DirtyPages = bm.dirtyCount
+	for _, frame := range bm.frames {
+		if frame.pinCount > 0 {
+			stats.PinnedPages++
+		}
+	}
+	return stats
+}
+
+/*
+WritePrometheus writes the counters of the BufferManager in the Prometheus text exposition format
+*/
+func (bm *BufferManager) WritePrometheus(w io.Writer) error {
+	return bm.Stats().WritePrometheus(w)
+}
+
+/*
+WritePrometheus writes the stats in the Prometheus text exposition format
+*/
+func (s Stats) WritePrometheus(w io.Writer) error {
+	out := bufio.NewWriter(w)
+	metric := func(name string, kind string, help string, value string) {
+		fmt.Fprintf(out, "# HELP %s %s\n# TYPE %s %s\n%s %s\n", name, help, name, kind, name, value)
+	}
+	counter := func(name string, help string, value uint64) {
+		metric(name, "counter", help, strconv.FormatUint(value, 10))
+	}
+	gauge := func(name string, help string, value int) {
+		metric(name, "gauge", help, strconv.Itoa(value))
+	}
+
+	counter("dmds_buffer_pins_total", "Pages pinned.", s.Pins)
+	counter("dmds_buffer_hits_total", "Pins that found the page in the buffer.", s.Hits)
+	counter("dmds_buffer_misses_total", "Pins that read the page from disk.", s.Misses)
+	counter("dmds_buffer_evictions_total", "Pages evicted from the buffer.", s.Evictions)
+	counter("dmds_buffer_write_backs_total", "Dirty pages written to disk.", s.WriteBacks)
+	counter("dmds_buffer_read_bytes_total", "Bytes of pages read from disk.", s.BytesRead)
+	counter("dmds_buffer_written_bytes_total", "Bytes of pages written to disk.", s.BytesWritten)
+	counter("dmds_buffer_prefetches_total", "Pages read ahead.", s.Prefetches)
+	counter("dmds_buffer_prefetch_hits_total", "Pins that found a page that has been read ahead.", s.PrefetchHits)
+	counter("dmds_buffer_views_total", "Pages read in place from a mapped file.", s.Views)
+	gauge("dmds_buffer_frames", "Frames in the buffer.", s.Frames)
+	gauge("dmds_buffer_dirty_pages", "Dirty pages in the buffer.", s.DirtyPages)
+	gauge("dmds_buffer_pinned_pages", "Pinned pages in the buffer.", s.PinnedPages)
+
+	name := "dmds_buffer_pin_wait_seconds"
+	fmt.Fprintf(out, "# HELP %s Time pins waited for a frame or a concurrent read.\n# TYPE %s histogram\n", name, name)
+	var cumulative uint64
+	for i, bound := range s.PinWait.Bounds {
+		cumulative += s.PinWait.Counts[i]
+		fmt.Fprintf(out, "%s_bucket{le=\"%s\"} %d\n", name, strconv.FormatFloat(bound, 'g', -1, 64), cumulative)
+	}
+	fmt.Fprintf(out, "%s_bucket{le=\"+Inf\"} %d\n", name, s.PinWait.Count)
+	fmt.Fprintf(out, "%s_sum %s\n", name, strconv.FormatFloat(s.PinWait.Sum, 'g', -1, 64))
+	fmt.Fprintf(out, "%s_count %d\n", name, s.PinWait.Count)
+	return out.Flush()
+}
@@ -0,0 +1,112 @@
+package src
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+/*
+TestStatsCounters tests that pins, hits, misses, evictions and write backs are counted
+*/
+func TestStatsCounters(t *testing.T) {
+	storage := NewMemoryStorage()
+	createTreeInStorage(t, storage, "testFileForStats")
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(2*PageSize), WithStorage(storage))
+
+	first, _ := myBuffer.Pin("testFileForStats", 0)
+	second, _ := myBuffer.Pin("testFileForStats", 1)
+	_, _ = myBuffer.Pin("testFileForStats", 0)
+	_ = myBuffer.Unpin(first, false)
+	_ = myBuffer.Unpin(first, false)
+	_ = myBuffer.Unpin(second, true)
+	// page 1 is the least recently used page and has to be written back
+	third, _ := myBuffer.Pin("testFileForStats", 2)
+
+	stats := myBuffer.Stats()
+	expected := Stats{Pins: 4, Hits: 1, Misses: 3, Evictions: 1, WriteBacks: 1, BytesRead: 3 * PageSize, BytesWritten: PageSize, Frames: 2, DirtyPages: 0, PinnedPages: 1}
+	pinWait := stats.PinWait
+	stats.PinWait = Histogram{}
+	if !reflect.DeepEqual(stats, expected) {
+		t.Fatalf("stats are %+v instead of %+v", stats, expected)
+	}
+	if pinWait.Count != 4 || pinWait.Counts[0] != 4 {
+		t.Fatalf("pin wait histogram is %+v instead of 4 pins without waiting", pinWait)
+	}
+
+	pinWait.Counts[0] = 0
+	if myBuffer.Stats().PinWait.Counts[0] != 4 {
+		t.Fatal("changing the snapshot changed the stats of the BufferManager")
+	}
+	_ = myBuffer.Unpin(third, false)
+}
+
+/*
+TestStatsFailedPinsAreNoMisses tests that only pins that read their page count as misses, so hits and misses add up to the pins
+*/
+func TestStatsFailedPinsAreNoMisses(t *testing.T) {
+	storage := NewMemoryStorage()
+	createTreeInStorage(t, storage, "testFileForStatsMisses")
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(PageSize), WithStorage(storage))
+
+	pageID, _ := myBuffer.Pin("testFileForStatsMisses", 0)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		_ = myBuffer.Unpin(pageID, false)
+	}()
+	otherID, _, err := myBuffer.PinContext(context.Background(), "testFileForStatsMisses", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := myBuffer.Pin("testFileForStatsMisses", 2); err == nil {
+		t.Fatal("pinning a page without a free frame did not fail")
+	}
+	_ = myBuffer.Unpin(otherID, false)
+	if _, err := myBuffer.Pin("testFileForStatsMisses", 7); err == nil {
+		t.Fatal("pinning a page after the end of the file did not fail")
+	}
+
+	stats := myBuffer.Stats()
+	if stats.Pins != 2 || stats.Hits != 0 || stats.Misses != 2 {
+		t.Errorf("%d pins are counted as %d hits and %d misses instead of 2 misses", stats.Pins, stats.Hits, stats.Misses)
+	}
+}
+
+/*
+TestStatsPrometheus tests that the stats are written in the Prometheus text exposition format
+*/
+func TestStatsPrometheus(t *testing.T) {
+	storage := NewMemoryStorage()
+	createTreeInStorage(t, storage, "testFileForPrometheus")
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(2*PageSize), WithStorage(storage))
+	pageID, _ := myBuffer.Pin("testFileForPrometheus", 0)
+	_ = myBuffer.Unpin(pageID, true)
+	_ = myBuffer.Flush()
+
+	var out bytes.Buffer
+	err := myBuffer.WritePrometheus(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"# TYPE dmds_buffer_pins_total counter",
+		"dmds_buffer_pins_total 1",
+		"dmds_buffer_misses_total 1",
+		"dmds_buffer_write_backs_total 1",
+		"dmds_buffer_written_bytes_total 128",
+		"# TYPE dmds_buffer_frames gauge",
+		"dmds_buffer_frames 2",
+		"# TYPE dmds_buffer_pin_wait_seconds histogram",
+		"dmds_buffer_pin_wait_seconds_bucket{le=\"0.0001\"} 1",
+		"dmds_buffer_pin_wait_seconds_bucket{le=\"10\"} 1",
+		"dmds_buffer_pin_wait_seconds_bucket{le=\"+Inf\"} 1",
+		"dmds_buffer_pin_wait_seconds_count 1",
+	} {
+		if !strings.Contains(out.String(), line+"\n") {
+			t.Fatalf("output does not contain %q:\n%s", line, out.String())
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package src
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+/*
+Storage holds the files of the trees, a BufferManager does all of its I/O through it.
+Names are relative to the storage, a missing file is reported with an error that matches os.ErrNotExist.
+*/
+type Storage interface {
+	// Open opens the file, with create set a file that does not exist yet is created empty
+	Open(name string, create bool) (StorageFile, error)
+	// Remove deletes the file
+	Remove(name string) error
+	// Rename replaces newName with the file oldName
+	Rename(oldName string, newName string) error
+	// Exists reports if there is a file with the name
+	Exists(name string) (bool, error)
+}
+
+/*
+StorageFile is an open file of a Storage
+*/
+type StorageFile interface {
+	io.ReaderAt
+	io.WriterAt
+	// Size returns the length of the file in bytes
+	Size() (int64, error)
+	// Truncate changes the length of the file
+	Truncate(size int64) error
+	// Sync makes the written data durable
+	Sync() error
+	// Close releases the file
+	Close() error
+}
+
+/*
+Mapper is implemented by a StorageFile that can be mapped into memory, see WithMmap
+*/
+type Mapper interface {
+	// Map maps the first size bytes of the file read-only
+	Map(size int64) ([]byte, error)
+	// Unmap releases a mapping returned by Map
+	Unmap(data []byte) error
+}
+
+/*
+readStorageFile returns the whole content of the file
+*/
+func readStorageFile(storage Storage, name string) ([]byte, error) {
+	file, err := storage.Open(name, false)
+	if err != nil {
+		return nil, err
+	}
+	content, err := readAll(file)
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	return content, err
+}
+
+/*
+readAll reads the whole content of an open file
+*/
+func readAll(file StorageFile) ([]byte, error) {
+	size, err := file.Size()
+	if err != nil {
+		return nil, err
+	}
+	content := make([]byte, size)
+	n, err := file.ReadAt(content, 0)
+	if err == io.EOF && int64(n) == size {
+		err = nil
+	}
+	return content, err
+}
+
+/*
+removeIfExists deletes the file, a missing file is not an error
+*/
+func removeIfExists(storage Storage, name string) error {
+	err := storage.Remove(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+/*
+writeFileAtomic replaces the content of the file through a temporary file that is renamed,
+so a crash leaves either the old or the new content behind. With sync set the data is synced before the rename.
+*/
+func writeFileAtomic(storage Storage, name string, content []byte, sync bool) error {
+	tmpName := name + ".tmp"
+	tmp, err := storage.Open(tmpName, true)
+	if err != nil {
+		return err
+	}
+	err = tmp.Truncate(0)
+	if err == nil {
+		_, err = tmp.WriteAt(content, 0)
+	}
+	if err == nil && sync {
+		err = tmp.Sync()
+	}
+	closeErr := tmp.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = storage.Rename(tmpName, name)
+	}
+	if err != nil {
+		_ = storage.Remove(tmpName)
+	}
+	return err
+}
@@ -0,0 +1,129 @@
+package src
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+/*
+TestStorageImplementations tests that both implementations behave the same for the operations the BufferManager relies on
+*/
+func TestStorageImplementations(t *testing.T) {
+	defer func() {
+		_ = os.Remove("./testFileForStorage")
+		_ = os.Remove("./testFileForStorageRenamed")
+	}()
+	for name, storage := range map[string]Storage{"disk": NewDiskStorage("./"), "memory": NewMemoryStorage()} {
+		_, err := storage.Open("testFileForStorage", false)
+		if !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("%s: opening a missing file returned %v instead of os.ErrNotExist", name, err)
+		}
+		file, err := storage.Open("testFileForStorage", true)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		_, _ = file.WriteAt([]byte("tree"), 4)
+		size, _ := file.Size()
+		if size != 8 {
+			t.Fatalf("%s: file has %d bytes instead of 8", name, size)
+		}
+		_ = file.Truncate(6)
+		buf := make([]byte, 4)
+		n, _ := file.ReadAt(buf, 2)
+		if n != 4 || string(buf) != "\x00\x00tr" {
+			t.Fatalf("%s: read %q instead of the written bytes", name, buf[:n])
+		}
+		_ = file.Close()
+
+		err = storage.Rename("testFileForStorage", "testFileForStorageRenamed")
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if exists, _ := storage.Exists("testFileForStorage"); exists {
+			t.Fatalf("%s: file still exists after it has been renamed", name)
+		}
+		content, err := readStorageFile(storage, "testFileForStorageRenamed")
+		if err != nil || string(content) != "\x00\x00\x00\x00tr" {
+			t.Fatalf("%s: renamed file contains %q, %v", name, content, err)
+		}
+		err = storage.Remove("testFileForStorageRenamed")
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !errors.Is(storage.Remove("testFileForStorageRenamed"), os.ErrNotExist) {
+			t.Fatalf("%s: removing a missing file should return os.ErrNotExist but does not", name)
+		}
+	}
+}
+
+/*
+TestStorageInMemoryTree tests that a tree can be converted, loaded and written without touching the file system
+*/
+func TestStorageInMemoryTree(t *testing.T) {
+	storage := NewMemoryStorage()
+	file, _ := storage.Open("testFileInMemory", true)
+	_, _ = file.WriteAt([]byte("10;;;;;;1;2;;;;;\n1;;;;;;2;;;;;;\n11;;;;;;12;;;;;;"), 0)
+	_ = file.Close()
+
+	myConverter := Converter{Storage: storage}
+	err := myConverter.TextToBinary("testFileInMemory", "testFileInMemory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var myBuffer, _ = CreateNewBufferManager("./", uint64(1024), WithStorage(storage))
+	var myLoader = Loader{}
+	tree, err := myLoader.Load("testFileInMemory", myBuffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tree.Push(12, 13)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = myBuffer.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat("./testFileInMemory"); !os.IsNotExist(err) {
+		t.Fatal("in memory tree has been written to the file system")
+	}
+	_ = myBuffer.Close()
+	var otherBuffer, _ = CreateNewBufferManager("./", uint64(1024), WithStorage(storage))
+	tree, _ = myLoader.Load("testFileInMemory", otherBuffer)
+	value, err := tree.Get(12)
+	if err != nil || value != 13 {
+		t.Fatalf("Get returned %d, %v instead of 13", value, err)
+	}
+	err = otherBuffer.Delete("testFileInMemory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists, _ := storage.Exists("testFileInMemory"); exists {
+		t.Fatal("tree file still exists after it has been deleted")
+	}
+}
+
+/*
+TestMemoryStorageGrowth tests that space reused after a shrink reads back as zeros and that appends keep earlier content
+*/
+func TestMemoryStorageGrowth(t *testing.T) {
+	file, _ := NewMemoryStorage().Open("testFileForGrowth", true)
+	for i := 0; i < 1000; i++ {
+		_, _ = file.WriteAt([]byte{byte(i)}, int64(i))
+	}
+	_ = file.Truncate(2)
+	_, _ = file.WriteAt([]byte("z"), 5)
+	buf := make([]byte, 6)
+	n, _ := file.ReadAt(buf, 0)
+	if string(buf[:n]) != "\x00\x01\x00\x00\x00z" {
+		t.Errorf("file contains %q after shrinking and growing", buf[:n])
+	}
+	_ = file.Truncate(8)
+	buf = make([]byte, 8)
+	n, _ = file.ReadAt(buf, 0)
+	if string(buf[:n]) != "\x00\x01\x00\x00\x00z\x00\x00" {
+		t.Errorf("file contains %q after growing with Truncate", buf[:n])
+	}
+}
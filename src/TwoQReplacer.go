@@ -0,0 +1,109 @@
+package src
+
+import "container/list"
+
+/*
+twoQReplacer implements the full 2Q algorithm. Pages that are loaded for the first time go into the FIFO queue a1in.
+Only pages that are accessed again after they have left a1in, which is remembered in the ghost queue a1out,
+are promoted into the LRU queue am. Long scans therefore only cycle through a1in.
+*/
+type twoQReplacer struct {
+	kin       int // target size of a1in
+	kout      int // maximum number of remembered pages in a1out
+	a1in      *list.List
+	am        *list.List
+	a1out     *list.List // keys of the pages evicted from a1in, the most recent one at the front
+	elements  map[uint64]*list.Element
+	queues    map[uint64]*list.List // the queue each tracked frame is in
+	pages     map[uint64]PageKey    // the page each tracked frame holds
+	ghosts    map[PageKey]*list.Element
+	evictable map[uint64]bool
+}
+
+func new2QReplacer(frames int) *twoQReplacer {
+	kin := frames / 4
+	if kin < 1 {
+		kin = 1
+	}
+	kout := frames / 2
+	if kout < 1 {
+		kout = 1
+	}
+	return &twoQReplacer{
+		kin:       kin,
+		kout:      kout,
+		a1in:      list.New(),
+		am:        list.New(),
+		a1out:     list.New(),
+		elements:  make(map[uint64]*list.Element),
+		queues:    make(map[uint64]*list.List),
+		pages:     make(map[uint64]PageKey),
+		ghosts:    make(map[PageKey]*list.Element),
+		evictable: make(map[uint64]bool),
+	}
+}
+
+func (r *twoQReplacer) RecordAccess(frameID uint64, page PageKey) {
+	if queue, ok := r.queues[frameID]; ok {
+		// pages in a1in are not promoted on correlated accesses right after they have been loaded
+		if queue == r.am {
+			r.am.MoveToFront(r.elements[frameID])
+		}
+		return
+	}
+
+	queue := r.a1in
+	if ghost, ok := r.ghosts[page]; ok {
+		r.a1out.Remove(ghost)
+		delete(r.ghosts, page)
+		queue = r.am
+	}
+	r.elements[frameID] = queue.PushFront(frameID)
+	r.queues[frameID] = queue
+	r.pages[frameID] = page
+}
+
+func (r *twoQReplacer) SetEvictable(frameID uint64, evictable bool) {
+	if _, ok := r.queues[frameID]; ok {
+		r.evictable[frameID] = evictable
+	}
+}
+
+func (r *twoQReplacer) Evict() (uint64, bool) {
+	var e *list.Element
+	if r.a1in.Len() > r.kin {
+		e = lastEvictable(r.a1in, r.evictable)
+	}
+	if e == nil {
+		e = lastEvictable(r.am, r.evictable)
+	}
+	if e == nil {
+		e = lastEvictable(r.a1in, r.evictable)
+	}
+	if e == nil {
+		return 0, false
+	}
+
+	frameID := e.Value.(uint64)
+	if r.queues[frameID] == r.a1in {
+		page := r.pages[frameID]
+		r.ghosts[page] = r.a1out.PushFront(page)
+		if r.a1out.Len() > r.kout {
+			delete(r.ghosts, r.a1out.Remove(r.a1out.Back()).(PageKey))
+		}
+	}
+	r.Remove(frameID)
+	return frameID, true
+}
+
+func (r *twoQReplacer) Remove(frameID uint64) {
+	queue, ok := r.queues[frameID]
+	if !ok {
+		return
+	}
+	queue.Remove(r.elements[frameID])
+	delete(r.elements, frameID)
+	delete(r.queues, frameID)
+	delete(r.pages, frameID)
+	delete(r.evictable, frameID)
+}